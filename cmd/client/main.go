@@ -1,32 +1,220 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/Archer-01/taskmaster/internal/client"
+	"github.com/Archer-01/taskmaster/internal/job"
+	"github.com/Archer-01/taskmaster/internal/manager"
 	"github.com/Archer-01/taskmaster/internal/parser/interpreter"
 	"github.com/Archer-01/taskmaster/internal/server"
 	"github.com/Archer-01/taskmaster/internal/utils"
 	"github.com/chzyer/readline"
 )
 
+const (
+	EXIT_OK    = 0
+	EXIT_ERROR = 1
+	EXIT_FATAL = 2
+)
+
+const (
+	START_TIMEOUT  = 30 * time.Second
+	START_INTERVAL = 250 * time.Millisecond
+)
+
 func main() {
-	setup, err := utils.ParseSetupFile()
+	var socket, setupFile string
+	flag.StringVar(&socket, "s", "", "control socket path (overrides the setup file)")
+	flag.StringVar(&socket, "socket", "", "control socket path (overrides the setup file)")
+	flag.StringVar(&setupFile, "c", "", "setup file path")
+	flag.StringVar(&setupFile, "setup", "", "setup file path")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] [command [args...]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	setup, err := utils.ParseSetupFile(setupFile)
 	if err != nil {
 		utils.Errorf(err.Error())
-		return
+		os.Exit(EXIT_ERROR)
+	}
+	if socket != "" {
+		setup.Socket = socket
 	}
 
 	client, err := client.NewClient(setup.Socket)
 	if err != nil {
 		utils.Errorf(err.Error())
-		return
+		os.Exit(EXIT_ERROR)
 	}
 	defer client.Close()
 
+	if flag.NArg() != 0 {
+		code := oneShot(client, setup.Socket, flag.Args())
+		client.Close()
+		os.Exit(code)
+	}
+
+	repl(client, setup)
+}
+
+// follow streams a program output or events on a dedicated connection
+// until the daemon hangs up or the user interrupts it.
+func follow(socket string, args []string) int {
+	c, err := client.NewClient(socket)
+	if err != nil {
+		utils.Errorf(err.Error())
+		return EXIT_ERROR
+	}
+
+	sigs := make(chan os.Signal, 1)
+	defer close(sigs)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	interrupted := make(chan struct{})
+	go func() {
+		if _, ok := <-sigs; ok {
+			close(interrupted)
+			c.Close()
+		}
+	}()
+
+	failed := false
+	err = c.Stream(func(reply *server.Reply) error {
+		res := reply.Response()
+		if res.Err != nil {
+			failed = true
+			return res.Err
+		}
+		fmt.Print(res.Data)
+		return nil
+	}, args[0], args[1:]...)
+
+	select {
+	case <-interrupted:
+		return EXIT_OK
+	default:
+		c.Close()
+	}
+
+	if err != nil {
+		utils.Errorf(err.Error())
+	}
+	if failed || err != nil {
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+func isFollow(args []string) bool {
+	switch args[0] {
+	case interpreter.TAIL:
+		return slices.Contains(args[1:], manager.FOLLOW)
+	case interpreter.SUBSCRIBE:
+		return true
+	}
+	return false
+}
+
+func oneShot(c *client.Client, socket string, line []string) int {
+	args, err := interpreter.Parse(strings.Join(line, " "))
+	if err != nil {
+		utils.Errorf(err.Error())
+		return EXIT_ERROR
+	}
+	if args[0] == interpreter.EXIT {
+		return EXIT_OK
+	}
+	if isFollow(args) {
+		return follow(socket, args)
+	}
+
+	reply, err := c.Call(args[0], args[1:]...)
+	if err == io.EOF && args[0] == interpreter.QUIT {
+		// the daemon may close the socket before its reply gets out
+		return EXIT_OK
+	}
+	if err != nil {
+		utils.Errorf(err.Error())
+		return EXIT_ERROR
+	}
+
+	res := reply.Response()
+	if res.Err != nil {
+		utils.Errorf(res.Err.Error())
+		return EXIT_ERROR
+	}
+	if res.HasContent() {
+		utils.Logf(res.Data)
+	}
+
+	status := reply.Status
+	switch args[0] {
+	case interpreter.START, interpreter.RESTART:
+		status, err = waitStarted(c, args[1:])
+		if err != nil {
+			utils.Errorf(err.Error())
+			return EXIT_ERROR
+		}
+	}
+
+	for _, st := range status {
+		if st.State == job.FATAL {
+			return EXIT_FATAL
+		}
+	}
+	return EXIT_OK
+}
+
+// settled reports whether a process is done starting: RUNNING for its
+// startsecs, or out of the start states.
+func settled(st job.Status) bool {
+	switch st.State {
+	case job.STARTING, job.BACKOFF:
+		return false
+	case job.RUNNING:
+		return st.Uptime >= int64(st.StartSecs)
+	}
+	return true
+}
+
+// waitStarted polls the status of started targets until every process has
+// settled, giving up START_TIMEOUT past what a process still has to wait for.
+func waitStarted(c *client.Client, targets []string) ([]job.Status, error) {
+	begin := time.Now()
+	for {
+		reply, err := c.Call(interpreter.STATUS, targets...)
+		if err != nil {
+			return nil, err
+		}
+		if reply.Error != nil {
+			return nil, fmt.Errorf("%s", reply.Error.Message)
+		}
+
+		i := slices.IndexFunc(reply.Status, func(st job.Status) bool { return !settled(st) })
+		if i == -1 {
+			return reply.Status, nil
+		}
+		st := reply.Status[i]
+		pending := time.Duration(st.StartSecs+int(st.NextRetry)) * time.Second
+		if time.Since(begin) > START_TIMEOUT+pending {
+			return nil, fmt.Errorf("%s is still %s", st.Name, st.State)
+		}
+		time.Sleep(START_INTERVAL)
+	}
+}
+
+func repl(client *client.Client, setup utils.Setup) {
 	rl, err := readline.New(setup.Prompt)
 	if err != nil {
 		panic(err)
@@ -61,6 +249,10 @@ func main() {
 		if args[0] == interpreter.EXIT {
 			return
 		}
+		if isFollow(args) {
+			follow(setup.Socket, args)
+			continue
+		}
 
 		err = client.Send(strings.Join(args, " "))
 		if err != nil {
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
+	"os"
 	"sync"
 
+	"github.com/Archer-01/taskmaster/internal/job"
 	"github.com/Archer-01/taskmaster/internal/logger"
 	"github.com/Archer-01/taskmaster/internal/manager"
 	"github.com/Archer-01/taskmaster/internal/server"
@@ -10,9 +13,18 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == job.SPAWN_ARG {
+		job.Spawn(os.Args[2:])
+	}
+
+	var setupFile string
+	flag.StringVar(&setupFile, "c", "", "setup file path")
+	flag.StringVar(&setupFile, "setup", "", "setup file path")
+	flag.Parse()
+
 	logger.Init()
 
-	setup, err := utils.ParseSetupFile()
+	setup, err := utils.ParseSetupFile(setupFile)
 	if err != nil {
 		logger.Critical(err)
 	}
@@ -32,6 +44,24 @@ func main() {
 		logger.Critical(err)
 	}
 
+	var Http *server.HttpServer
+	if setup.Http != "" {
+		Http = server.NewHttpServer(setup.Http, Manager)
+		err = Http.Init()
+		if err != nil {
+			logger.Critical(err)
+		}
+	}
+
+	var Metrics *server.HttpServer
+	if setup.Metrics != "" {
+		Metrics = server.NewMetricsServer(setup.Metrics, Manager, Server)
+		err = Metrics.Init()
+		if err != nil {
+			logger.Critical(err)
+		}
+	}
+
 	Manager.InitSignals()
 	go Manager.WaitForSignals(&wg)
 	defer Manager.StopSignals()
@@ -39,5 +69,15 @@ func main() {
 	go Server.Start(&wg)
 	defer Server.Stop()
 
+	if Http != nil {
+		go Http.Start(&wg)
+		defer Http.Stop()
+	}
+
+	if Metrics != nil {
+		go Metrics.Start(&wg)
+		defer Metrics.Stop()
+	}
+
 	Manager.Run()
 }
@@ -5,5 +5,5 @@ go 1.23.4
 require github.com/BurntSushi/toml v1.4.0 // indirect
 require (
 	github.com/chzyer/readline v1.5.1 // indirect
-	golang.org/x/sys v0.0.0-20220310020820-b874c991c1a5 // indirect
+	golang.org/x/sys v0.0.0-20220310020820-b874c991c1a5
 )
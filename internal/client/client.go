@@ -2,6 +2,7 @@ package client
 
 import (
 	"bufio"
+	"encoding/json"
 	"io"
 	"net"
 
@@ -13,6 +14,7 @@ type Client struct {
 	Socket net.Conn
 	Rd     *bufio.Reader
 	Buf    string
+	nextId int
 }
 
 func NewClient(socket string) (*Client, error) {
@@ -64,3 +66,67 @@ func (c *Client) Read(del byte) *manager.Response {
 		return manager.NewResponseWithBody(line)
 	}
 }
+
+func (c *Client) sendRequest(command string, args []string) error {
+	c.nextId++
+	id, err := json.Marshal(c.nextId)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(server.Request{
+		Version: server.PROTOCOL_VERSION,
+		Id:      id,
+		Command: command,
+		Args:    args,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Socket.Write(append(data, server.JSON_DEL))
+	return err
+}
+
+func (c *Client) readReply() (*server.Reply, error) {
+	line, err := c.Rd.ReadBytes(server.JSON_DEL)
+	if err != nil {
+		return nil, err
+	}
+
+	var reply server.Reply
+	if err := json.Unmarshal(line, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// Call sends a command using the JSON protocol and waits for its reply.
+// A connection must not mix Call with Send/Read.
+func (c *Client) Call(command string, args ...string) (*server.Reply, error) {
+	if err := c.sendRequest(command, args); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// Stream sends a command using the JSON protocol and hands every reply to fn
+// until the connection is closed or fn returns an error.
+func (c *Client) Stream(fn func(*server.Reply) error, command string, args ...string) error {
+	if err := c.sendRequest(command, args); err != nil {
+		return err
+	}
+
+	for {
+		reply, err := c.readReply()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(reply); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	PROCESS_STATE_STOPPED   Type = "PROCESS_STATE_STOPPED"
+	PROCESS_STATE_STARTING  Type = "PROCESS_STATE_STARTING"
+	PROCESS_STATE_RUNNING   Type = "PROCESS_STATE_RUNNING"
+	PROCESS_STATE_BACKOFF   Type = "PROCESS_STATE_BACKOFF"
+	PROCESS_STATE_STOPPING  Type = "PROCESS_STATE_STOPPING"
+	PROCESS_STATE_EXITED    Type = "PROCESS_STATE_EXITED"
+	PROCESS_STATE_FATAL     Type = "PROCESS_STATE_FATAL"
+	PROCESS_STATE_UNHEALTHY Type = "PROCESS_STATE_UNHEALTHY"
+	PROCESS_STATE_UNKNOWN   Type = "PROCESS_STATE_UNKNOWN"
+
+	SUPERVISOR_STATE_RUNNING  Type = "SUPERVISOR_STATE_CHANGE_RUNNING"
+	SUPERVISOR_STATE_STOPPING Type = "SUPERVISOR_STATE_CHANGE_STOPPING"
+	SUPERVISOR_RELOAD         Type = "SUPERVISOR_RELOAD"
+)
+
+const (
+	PROCESS_STATE_PREFIX = "PROCESS_STATE_"
+	SUBSCRIPTION_BUFFER  = 256
+)
+
+func ProcessState(state string) Type {
+	return Type(PROCESS_STATE_PREFIX + state)
+}
+
+type Event struct {
+	Serial    uint64    `json:"serial"`
+	Type      Type      `json:"type"`
+	Time      time.Time `json:"time"`
+	Program   string    `json:"program,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	ProcId    int       `json:"proc_id"`
+	Pid       int       `json:"pid,omitempty"`
+	FromState string    `json:"from_state,omitempty"`
+	ExitCode  *int      `json:"exit_code,omitempty"`
+	Signal    string    `json:"signal,omitempty"`
+}
+
+type Filter = func(e Event) bool
+
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	filter  Filter
+	dropped int
+}
+
+// Dropped returns how many events were discarded because the subscriber
+// did not keep up.
+func (s *Subscription) Dropped() int {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	return s.dropped
+}
+
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	serial uint64
+}
+
+var bus = Bus{subs: make(map[*Subscription]struct{})}
+
+// Publish hands e to every matching subscriber without ever blocking:
+// subscribers whose buffer is full miss the event.
+func Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.serial++
+	e.Serial = bus.serial
+	for sub := range bus.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// Subscribe registers a subscriber receiving the events accepted by filter,
+// or every event when filter is nil.
+func Subscribe(filter Filter) *Subscription {
+	ch := make(chan Event, SUBSCRIPTION_BUFFER)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops delivering events to sub and closes its channel.
+func Unsubscribe(sub *Subscription) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if _, found := bus.subs[sub]; found {
+		delete(bus.subs, sub)
+		close(sub.ch)
+	}
+}
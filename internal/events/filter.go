@@ -0,0 +1,77 @@
+package events
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var processStates = []Type{
+	PROCESS_STATE_STOPPED,
+	PROCESS_STATE_STARTING,
+	PROCESS_STATE_RUNNING,
+	PROCESS_STATE_BACKOFF,
+	PROCESS_STATE_STOPPING,
+	PROCESS_STATE_EXITED,
+	PROCESS_STATE_FATAL,
+	PROCESS_STATE_UNHEALTHY,
+	PROCESS_STATE_UNKNOWN,
+}
+
+// ParseFilter builds a filter out of event types, bare process states such
+// as FATAL, and program or process names. An event passes when it matches
+// one of the given types, if any, and one of the given names, if any.
+func ParseFilter(args []string) Filter {
+	types := make([]Type, 0)
+	names := make([]string, 0)
+
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, PROCESS_STATE_PREFIX), strings.HasPrefix(arg, "SUPERVISOR_"):
+			types = append(types, Type(arg))
+		case slices.Contains(processStates, ProcessState(arg)):
+			types = append(types, ProcessState(arg))
+		default:
+			names = append(names, arg)
+		}
+	}
+
+	if len(types) == 0 && len(names) == 0 {
+		return nil
+	}
+
+	return func(e Event) bool {
+		if len(types) != 0 && !slices.Contains(types, e.Type) {
+			return false
+		}
+		if len(names) != 0 && !slices.Contains(names, e.Program) && !slices.Contains(names, e.Name) {
+			return false
+		}
+		return true
+	}
+}
+
+func Format(e Event) string {
+	line := fmt.Sprintf("%s %s", e.Time.Format("2006-01-02 15:04:05"), e.Type)
+	if e.Name == "" {
+		return line
+	}
+
+	line += " " + e.Name
+	details := make([]string, 0)
+	if e.Pid != 0 {
+		details = append(details, fmt.Sprintf("pid %d", e.Pid))
+	}
+	if e.FromState != "" {
+		details = append(details, "from "+e.FromState)
+	}
+	if e.Signal != "" {
+		details = append(details, "killed by signal "+e.Signal)
+	} else if e.ExitCode != nil {
+		details = append(details, fmt.Sprintf("exit code %d", *e.ExitCode))
+	}
+	if len(details) != 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+	return line
+}
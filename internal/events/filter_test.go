@@ -0,0 +1,75 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	fatalWeb := Event{Type: PROCESS_STATE_FATAL, Program: "web", Name: "web:web_1", ProcId: 1}
+	runningDb := Event{Type: PROCESS_STATE_RUNNING, Program: "db", Name: "db"}
+	reload := Event{Type: SUPERVISOR_RELOAD}
+
+	tests := []struct {
+		name   string
+		args   []string
+		events map[*Event]bool
+	}{
+		{
+			name:   "no arguments",
+			args:   nil,
+			events: map[*Event]bool{&fatalWeb: true, &runningDb: true, &reload: true},
+		},
+		{
+			name:   "bare state",
+			args:   []string{"FATAL"},
+			events: map[*Event]bool{&fatalWeb: true, &runningDb: false, &reload: false},
+		},
+		{
+			name:   "full type",
+			args:   []string{"PROCESS_STATE_RUNNING"},
+			events: map[*Event]bool{&fatalWeb: false, &runningDb: true, &reload: false},
+		},
+		{
+			name:   "supervisor type",
+			args:   []string{"SUPERVISOR_RELOAD"},
+			events: map[*Event]bool{&fatalWeb: false, &runningDb: false, &reload: true},
+		},
+		{
+			name:   "program name",
+			args:   []string{"web"},
+			events: map[*Event]bool{&fatalWeb: true, &runningDb: false, &reload: false},
+		},
+		{
+			name:   "process name",
+			args:   []string{"web:web_1"},
+			events: map[*Event]bool{&fatalWeb: true, &runningDb: false, &reload: false},
+		},
+		{
+			name:   "types or names",
+			args:   []string{"FATAL", "RUNNING", "web", "db"},
+			events: map[*Event]bool{&fatalWeb: true, &runningDb: true, &reload: false},
+		},
+		{
+			name:   "types and names",
+			args:   []string{"RUNNING", "web"},
+			events: map[*Event]bool{&fatalWeb: false, &runningDb: false, &reload: false},
+		},
+		{
+			name:   "lowercase state is a name",
+			args:   []string{"fatal"},
+			events: map[*Event]bool{&fatalWeb: false, &runningDb: false, &reload: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := ParseFilter(tt.args)
+			for e, want := range tt.events {
+				got := filter == nil || filter(*e)
+				if got != want {
+					t.Errorf("ParseFilter(%q) on %s %s = %t, want %t", tt.args, e.Type, e.Name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package job
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Archer-01/taskmaster/internal/logger"
+)
+
+const (
+	CGROUP_MAX = "max"
+)
+
+var cgroupControllers = []string{"memory", "cpu", "pids"}
+
+// A program with a cgroup parent gets <parent>/<program>, holding its limits,
+// and one <parent>/<program>/<procId> leaf per process, holding its usage.
+func (j *Job) cgroupDir() string {
+	return filepath.Join(j.CgroupParent, j.Name)
+}
+
+func (j *Job) cgroupLeaf(procId int) string {
+	return filepath.Join(j.cgroupDir(), strconv.Itoa(procId))
+}
+
+func writeCgroupFile(dir string, name string, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0)
+}
+
+// enableControllers lets the children of dir use the controllers it has.
+func enableControllers(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+
+	available := strings.Fields(string(data))
+	enable := make([]string, 0, len(cgroupControllers))
+	for _, controller := range cgroupControllers {
+		if slices.Contains(available, controller) {
+			enable = append(enable, "+"+controller)
+		}
+	}
+	if len(enable) == 0 {
+		return nil
+	}
+	return writeCgroupFile(dir, "cgroup.subtree_control", strings.Join(enable, " "))
+}
+
+// setCgroupLimits writes the limits of the program cgroup. Unset limits are
+// reset to max, unless the controller is not available at all.
+func (j *Job) setCgroupLimits() error {
+	pidsMax := CGROUP_MAX
+	if j.PidsMax != 0 {
+		pidsMax = strconv.Itoa(j.PidsMax)
+	}
+
+	for _, limit := range []struct{ file, value string }{
+		{"memory.max", j.MemoryMax},
+		{"cpu.max", j.CpuMax},
+		{"pids.max", pidsMax},
+	} {
+		set := limit.value != "" && limit.value != CGROUP_MAX
+		if limit.value == "" {
+			limit.value = CGROUP_MAX
+		}
+
+		path := filepath.Join(j.cgroupDir(), limit.file)
+		if _, err := os.Stat(path); err != nil && !set {
+			continue
+		}
+		if err := writeCgroupFile(j.cgroupDir(), limit.file, limit.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enterCgroup prepares the cgroup of a process and returns a descriptor to
+// start it in, or -1 when the program is not managed through cgroups.
+func (j *Job) enterCgroup(procId int) (int, error) {
+	if j.CgroupParent == "" {
+		return -1, nil
+	}
+
+	if err := os.MkdirAll(j.cgroupLeaf(procId), 0755); err != nil {
+		return -1, err
+	}
+	for _, dir := range []string{j.CgroupParent, j.cgroupDir()} {
+		if err := enableControllers(dir); err != nil {
+			return -1, err
+		}
+	}
+	if err := j.setCgroupLimits(); err != nil {
+		return -1, err
+	}
+
+	// the leaf outlives its processes, keep the CPU time of this one apart
+	base, _ := readCgroupKey(filepath.Join(j.cgroupLeaf(procId), "cpu.stat"), "usage_usec")
+	j.mucgroup.Lock()
+	j.cpuBase[procId] = base
+	j.mucgroup.Unlock()
+
+	return syscall.Open(j.cgroupLeaf(procId), syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+}
+
+// setCgroupParent moves a program whose processes are all gone to another
+// cgroup parent, removing the cgroups it had under the previous one.
+func (j *Job) setCgroupParent(parent string) {
+	if parent == j.CgroupParent {
+		return
+	}
+	j.RemoveCgroup()
+
+	j.mucgroup.Lock()
+	defer j.mucgroup.Unlock()
+	j.CgroupParent = parent
+}
+
+// RemoveCgroup deletes the cgroups of a program once all of its processes
+// are gone.
+func (j *Job) RemoveCgroup() {
+	if j.CgroupParent == "" {
+		return
+	}
+
+	for i := range j.NumProcs {
+		os.Remove(j.cgroupLeaf(i))
+	}
+	if err := os.Remove(j.cgroupDir()); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Program(name=%s) cgroup not removed: %s", j.Name, err)
+	}
+}
+
+func readCgroupKey(path string, key string) (int64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			value, err := strconv.ParseInt(fields[1], 10, 64)
+			return value, err == nil
+		}
+	}
+	return 0, false
+}
+
+func (j *Job) cgroupStatus(procId int, status *Status) {
+	j.mucgroup.Lock()
+	defer j.mucgroup.Unlock()
+
+	if j.CgroupParent == "" {
+		return
+	}
+	leaf := j.cgroupLeaf(procId)
+
+	if kills, ok := readCgroupKey(filepath.Join(leaf, "memory.events"), "oom_kill"); ok {
+		status.OomKills = kills
+	}
+
+	switch status.State {
+	case STARTING, RUNNING, UNHEALTHY, STOPPING:
+	default:
+		return
+	}
+
+	if data, err := os.ReadFile(filepath.Join(leaf, "memory.current")); err == nil {
+		if current, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			status.Memory = current
+		}
+	}
+	if usage, ok := readCgroupKey(filepath.Join(leaf, "cpu.stat"), "usage_usec"); ok {
+		status.CpuTime = (time.Duration(usage-j.cpuBase[procId]) * time.Microsecond).Seconds()
+	}
+}
@@ -0,0 +1,95 @@
+package job
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"syscall"
+
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+	"github.com/Archer-01/taskmaster/internal/utils"
+)
+
+func parseId(id string) (uint32, error) {
+	n, err := strconv.ParseUint(id, 10, 32)
+	return uint32(n), err
+}
+
+// credential resolves the user and group a program runs as, along with the
+// supplementary groups and the HOME, USER and LOGNAME variables of that user.
+// It returns a nil credential to keep the daemon one.
+func (j *Job) credential() (*syscall.Credential, []string, error) {
+	if j.User == "" && j.Group == "" {
+		return nil, nil, nil
+	}
+
+	cred := &syscall.Credential{
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}
+	var env []string
+
+	if j.User != "" {
+		u, err := config.LookupUser(j.User)
+		if err != nil {
+			return nil, nil, err
+		}
+		env = utils.AccountEnv(u)
+		if cred.Uid, err = parseId(u.Uid); err != nil {
+			return nil, nil, err
+		}
+		if cred.Gid, err = parseId(u.Gid); err != nil {
+			return nil, nil, err
+		}
+
+		groups, err := u.GroupIds()
+		if err != nil {
+			return nil, nil, fmt.Errorf("groups of %s: %s", u.Username, err)
+		}
+		for _, group := range groups {
+			gid, err := parseId(group)
+			if err != nil {
+				return nil, nil, err
+			}
+			cred.Groups = append(cred.Groups, gid)
+		}
+	}
+
+	if j.Group != "" {
+		g, err := config.LookupGroup(j.Group)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cred.Gid, err = parseId(g.Gid); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// A daemon that dropped root cannot call setgroups, even for its own
+	// account, which is the only one the configuration lets it use.
+	if os.Getuid() != 0 && cred.Uid == uint32(os.Getuid()) && cred.Gid == uint32(os.Getgid()) {
+		return nil, env, nil
+	}
+
+	if !slices.Contains(cred.Groups, cred.Gid) {
+		cred.Groups = append(cred.Groups, cred.Gid)
+	}
+	return cred, env, nil
+}
+
+// environ builds the environment of a program process: the daemon one unless
+// clean_environment is set, then the account of the process, then the
+// program own variables. The last value of a variable wins.
+func (j *Job) environ(procId int, account []string, extra ...string) []string {
+	var daemon []string
+	if !j.CleanEnv {
+		daemon = os.Environ()
+	}
+
+	env := make([]string, len(j.Environment))
+	for i, value := range j.Environment {
+		env[i] = config.ExpandProcess(value, procId)
+	}
+	return slices.Concat(daemon, account, env, extra)
+}
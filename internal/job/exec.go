@@ -0,0 +1,23 @@
+package job
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+)
+
+// command builds the command of a process: the configured line run by sh in
+// shell mode, or its parsed argv executed as is in direct mode.
+func (j *Job) command(procId int) (*exec.Cmd, error) {
+	command := config.ExpandProcess(j.Command, procId)
+	if j.Exec != config.EXEC_DIRECT {
+		return exec.Command("sh", "-c", fmt.Sprintf("umask %v && %v", j.Umask, command)), nil
+	}
+
+	argv, err := config.ParseCommand(command)
+	if err != nil {
+		return nil, fmt.Errorf("Program(name=%s) command: %s", j.Name, err)
+	}
+	return exec.Command(argv[0], argv[1:]...), nil
+}
@@ -0,0 +1,110 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Archer-01/taskmaster/internal/logger"
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+)
+
+const (
+	HEALTH_NONE = "none"
+	HEALTH_EXEC = "exec"
+	HEALTH_TCP  = "tcp"
+	HEALTH_HTTP = "http"
+)
+
+func (j *Job) probe(procId int) error {
+	check := j.HealthCheck
+	timeout := time.Duration(check.Timeout) * time.Second
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(check.Port))
+
+	switch check.Type {
+	case HEALTH_EXEC:
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		cred, account, err := j.credential()
+		if err != nil {
+			return err
+		}
+
+		// like hooks, a probe that times out is killed with everything it spawned
+		cmd := exec.CommandContext(ctx, "sh", "-c", check.Command)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Credential: cred}
+		cmd.Cancel = func() error {
+			return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		cmd.WaitDelay = time.Second
+		cmd.Env = j.environ(procId, account)
+		cmd.Dir = config.ExpandProcess(j.Dir, procId)
+		return cmd.Run()
+
+	case HEALTH_TCP:
+		con, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return err
+		}
+		return con.Close()
+
+	case HEALTH_HTTP:
+		client := http.Client{Timeout: timeout}
+		res, err := client.Get("http://" + addr + check.Path)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("%s", res.Status)
+		}
+		return nil
+	}
+	return nil
+}
+
+// watchHealth probes a RUNNING process every interval until exited is
+// closed. After enough consecutive failures the process is marked UNHEALTHY
+// and restarted.
+func (j *Job) watchHealth(wg *sync.WaitGroup, id int, exited <-chan struct{}) {
+	if j.HealthCheck.Type == HEALTH_NONE {
+		return
+	}
+
+	j.healthFailures[id] = 0
+	for {
+		select {
+		case <-exited:
+			return
+		case <-time.After(time.Duration(j.HealthCheck.Interval) * time.Second):
+		}
+
+		if !j.Is(RUNNING, id) {
+			continue
+		}
+
+		err := j.probe(id)
+		if err == nil {
+			j.healthFailures[id] = 0
+			continue
+		}
+
+		j.healthFailures[id]++
+		logger.Warnf("Program(name=%s) health check failed (%d/%d): %s",
+			j.DisplayName(id), j.healthFailures[id], j.HealthCheck.Failures, err)
+
+		if j.healthFailures[id] >= j.HealthCheck.Failures {
+			j.SetState(UNHEALTHY, id)
+			logger.Warnf("[RESTARTING] Program(name=%s) is unhealthy", j.DisplayName(id))
+			go j.Restart(wg, make(chan bool, 1), id)
+			return
+		}
+	}
+}
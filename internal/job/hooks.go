@@ -0,0 +1,104 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Archer-01/taskmaster/internal/logger"
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+)
+
+const (
+	PRE_START  = "pre_start"
+	POST_START = "post_start"
+	PRE_STOP   = "pre_stop"
+	POST_STOP  = "post_stop"
+)
+
+func (j *Job) hookCommand(hook string) string {
+	switch hook {
+	case PRE_START:
+		return j.PreStart
+	case POST_START:
+		return j.PostStart
+	case PRE_STOP:
+		return j.PreStop
+	case POST_STOP:
+		return j.PostStop
+	}
+	return ""
+}
+
+func (j *Job) hookEnv(hook string, procId int) []string {
+	env := []string{
+		"TASKMASTER_HOOK=" + hook,
+		"TASKMASTER_PROGRAM=" + j.Name,
+		"TASKMASTER_PROCESS_NAME=" + j.DisplayName(procId),
+		"TASKMASTER_PROCESS_NUM=" + strconv.Itoa(procId),
+	}
+	if pid := j.Pid(procId); pid != 0 {
+		env = append(env, "TASKMASTER_PID="+strconv.Itoa(pid))
+	}
+	return env
+}
+
+// runHook runs a hook command in its own process group, killing the whole
+// group once HookTimeout is over. Its output goes to the program logs.
+func (j *Job) runHook(hook string, procId int) error {
+	command := j.hookCommand(hook)
+	if command == "" {
+		return nil
+	}
+
+	cred, account, err := j.credential()
+	if err != nil {
+		return fmt.Errorf("Program(name=%s) %s hook credential: %s", j.DisplayName(procId), hook, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(j.HookTimeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Credential: cred}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	cmd.Env = j.environ(procId, account, j.hookEnv(hook, procId)...)
+	cmd.Dir = config.ExpandProcess(j.Dir, procId)
+	cmd.Stdout = j.StdoutWriter
+	cmd.Stderr = j.StderrWriter
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("Program(name=%s) %s hook timed out after %ds", j.DisplayName(procId), hook, j.HookTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("Program(name=%s) %s hook failed: %s", j.DisplayName(procId), hook, err)
+	}
+	return nil
+}
+
+// runHooks runs a hook for every given process at once, logging failures.
+func (j *Job) runHooks(hook string, procIds ...int) {
+	if j.hookCommand(hook) == "" {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, i := range procIds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := j.runHook(hook, i); err != nil {
+				logger.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -3,8 +3,11 @@ package job
 import (
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"os"
 	"os/exec"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
@@ -17,17 +20,38 @@ import (
 type Job struct {
 	Name           string
 	Command        string
+	Exec           string
 	cmds           []*exec.Cmd
 	Environment    []string
+	CleanEnv       bool
 	Dir            string
 	Autostart      bool
 	StdoutLogFile  string
+	StdoutLogMax   int
+	StdoutLogKeep  int
 	StderrLogFile  string
+	StderrLogMax   int
+	StderrLogKeep  int
+	User           string
+	Group          string
 	Umask          string
+	Rlimits        []Rlimit
+	CgroupParent   string
+	MemoryMax      string
+	CpuMax         string
+	PidsMax        int
+	cpuBase        []int64
+	mucgroup       sync.Mutex
 	State          []string
 	StartSecs      int
 	StartRetries   int
+	BackoffInitial int
+	BackoffMax     int
+	BackoffFactor  float64
+	BackoffJitter  float64
 	Autorestart    string
+	MaxRestarts    int
+	RestartWindow  int
 	ExitCodes      []int
 	StopSignal     syscall.Signal
 	StopWaitSecs   int
@@ -35,10 +59,28 @@ type Job struct {
 	RedirectStderr bool
 	ProcessName    string
 	_running       []bool
+	startedAt      []time.Time
+	stoppedAt      []time.Time
+	exits          []*os.ProcessState
+	retries        []int
+	nextRetry      []time.Time
+	restarts       [][]time.Time
+	reasons        []string
 	StdoutWriter   *utils.DynamicWriter
 	StderrWriter   *utils.DynamicWriter
 	NumProcs       int
-	pgid           int
+	DependsOn      []string
+	WaitDeps       bool
+	HealthCheck    config.HealthCheck
+	healthFailures []int
+	PreStart       string
+	PostStart      string
+	PreStop        string
+	PostStop       string
+	HookTimeout    int
+	listener       *listener
+	counters       []Counters
+	mumetrics      sync.Mutex
 	startReady     chan struct{}
 	startOnce      sync.Once
 	mustop         sync.Mutex
@@ -74,16 +116,35 @@ func NewJob(name string, prog *config.Program) *Job {
 	return &Job{
 		Name:           name,
 		Command:        prog.Command,
+		Exec:           prog.Exec,
 		Dir:            prog.Directory,
 		Autostart:      prog.Autostart,
 		Environment:    prog.Environment,
+		CleanEnv:       prog.CleanEnvironment,
 		StdoutLogFile:  prog.StdoutLogFile,
+		StdoutLogMax:   prog.StdoutLogMaxBytes,
+		StdoutLogKeep:  prog.StdoutLogBackups,
 		StderrLogFile:  prog.StderrLogFile,
+		StderrLogMax:   prog.StderrLogMaxBytes,
+		StderrLogKeep:  prog.StderrLogBackups,
+		User:           prog.User,
+		Group:          prog.Group,
 		Umask:          prog.Umask,
+		Rlimits:        rlimits(prog),
+		MemoryMax:      prog.MemoryMax,
+		CpuMax:         prog.CpuMax,
+		PidsMax:        prog.PidsMax,
+		cpuBase:        make([]int64, prog.NumProcs),
 		State:          states,
 		StartSecs:      prog.StartSecs,
 		StartRetries:   prog.StartRetries,
+		BackoffInitial: prog.BackoffInitial,
+		BackoffMax:     prog.BackoffMax,
+		BackoffFactor:  prog.BackoffMultiplier,
+		BackoffJitter:  prog.BackoffJitter,
 		Autorestart:    prog.Autorestart,
+		MaxRestarts:    prog.MaxRestarts,
+		RestartWindow:  prog.RestartWindow,
 		ExitCodes:      exit_codes,
 		StopSignal:     utils.ParseSignal(prog.StopSignal),
 		StopWaitSecs:   prog.StopWaitSecs,
@@ -91,11 +152,27 @@ func NewJob(name string, prog *config.Program) *Job {
 		RedirectStderr: prog.RedirectStderr,
 		ProcessName:    prog.ProcessName,
 		_running:       running,
+		startedAt:      make([]time.Time, prog.NumProcs),
+		stoppedAt:      make([]time.Time, prog.NumProcs),
+		exits:          make([]*os.ProcessState, prog.NumProcs),
+		retries:        make([]int, prog.NumProcs),
+		nextRetry:      make([]time.Time, prog.NumProcs),
+		restarts:       make([][]time.Time, prog.NumProcs),
+		reasons:        make([]string, prog.NumProcs),
 		StdoutWriter:   &utils.DynamicWriter{},
 		StderrWriter:   &utils.DynamicWriter{},
 		NumProcs:       prog.NumProcs,
+		DependsOn:      prog.DependsOn,
+		WaitDeps:       prog.WaitDependencies,
+		HealthCheck:    prog.HealthCheck,
+		healthFailures: make([]int, prog.NumProcs),
+		counters:       make([]Counters, prog.NumProcs),
+		PreStart:       prog.PreStart,
+		PostStart:      prog.PostStart,
+		PreStop:        prog.PreStop,
+		PostStop:       prog.PostStop,
+		HookTimeout:    prog.HookTimeout,
 		cmds:           make([]*exec.Cmd, prog.NumProcs),
-		pgid:           0,
 		startReady:     ch,
 	}
 }
@@ -116,90 +193,142 @@ func (j *Job) DisplayName(procId int) string {
 	return fmt.Sprintf("%s_%d", j.Name, procId)
 }
 
-type WorkerFn = func(j *Job, wg *sync.WaitGroup, _done chan bool) error
+type WorkerFn = func(j *Job, wg *sync.WaitGroup, _done chan bool, procIds ...int) error
 
-func (j *Job) Start(wg *sync.WaitGroup, _done chan bool) error {
+func (j *Job) procIds(procIds []int) []int {
+	if len(procIds) != 0 {
+		return procIds
+	}
+	ids := make([]int, j.NumProcs)
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
+
+func (j *Job) Pid(procId int) int {
+	cmd := j.cmds[procId]
+	if cmd == nil || cmd.Process == nil {
+		return 0
+	}
+	return cmd.Process.Pid
+}
+
+func (j *Job) Start(wg *sync.WaitGroup, _done chan bool, procIds ...int) error {
 	defer func() { _done <- true }()
 
 	j.mustop.Lock()
 	defer j.mustop.Unlock()
 
-	startReady := make(chan struct{})
-	j.startReady = startReady
-	j.startOnce = sync.Once{}
-
-	for i := range j.NumProcs {
+	ids := make([]int, 0, j.NumProcs)
+	for _, i := range j.procIds(procIds) {
 		if j.Is(STOPPING, i) || j._running[i] {
 			continue
 		}
+		ids = append(ids, i)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
 
-		j._running[i] = true
+	startReady := make(chan struct{})
+	j.startReady = startReady
+	j.startOnce = sync.Once{}
 
-		if j.HasPgid() {
-			go j.startJobWorker(wg, i, j.pgid)
-			continue
-		}
+	for _, i := range ids {
+		j._running[i] = true
+		go j.startJobWorker(wg, i)
+	}
 
-		go j.startJobWorker(wg, i, 0)
-		<-startReady
-		if !j.Is(RUNNING, 0) {
-			return fmt.Errorf("process could not be running")
+	<-startReady
+	for _, i := range ids {
+		if j.Is(RUNNING, i) {
+			return nil
 		}
-		j.pgid = j.cmds[i].Process.Pid
 	}
-
-	return nil
+	return fmt.Errorf("process could not be running")
 }
 
-func (j *Job) startJobWorker(wg *sync.WaitGroup, id int, pgid int) {
+func (j *Job) startJobWorker(wg *sync.WaitGroup, id int) {
 	wg.Add(1)
 	defer wg.Done()
 
 	retries := 0
+	j.retries[id] = 0
+	j.reasons[id] = ""
 	for {
-		usePgid := pgid
-		if j.cmds[id] != nil && j.cmds[id].Process != nil {
-			usePgid = 0
+		if j.Is(STOPPING, id) {
+			break
 		}
 
-		cmd := exec.Command("sh", "-c", fmt.Sprintf("umask %v && %v", j.Umask, j.Command))
-		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: usePgid}
+		// every process leads its own group so it can be signaled alone
+		cmd, err := j.command(id)
+		if err == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		}
+		// installed before STARTING so that the pid of the previous run is gone
 		j.cmds[id] = cmd
-
 		j.SetState(STARTING, id)
-		err := j.tryStart(id)
+		var stdin io.WriteCloser
+		var stdout io.ReadCloser
+		if err == nil && j.listener != nil {
+			stdin, stdout, err = j.listener.pipe(cmd)
+		}
+		if err == nil {
+			err = j.tryStart(id)
+		}
 		if err != nil {
+			if stdin != nil {
+				stdin.Close()
+				stdout.Close()
+			}
 			logger.Error(err)
+			j.exits[id] = nil
+			j.stoppedAt[id] = time.Now()
+			retries++
+			j.retries[id] = retries
 			j.SetState(BACKOFF, id)
 			j.closeStartReady()
-			retries++
 			if j.StartRetries == retries {
 				break
 			}
-			time.Sleep(1 * time.Second)
+			j.backoff(id, retries)
 			continue
 		}
 
 		cur_ts := int(time.Now().Unix())
+		j.startedAt[id] = time.Now()
 		j.SetState(RUNNING, id)
 		j.closeStartReady()
+		go j.runHooks(POST_START, id)
+		exited := make(chan struct{})
+		go j.watchHealth(wg, id, exited)
+		if j.listener != nil {
+			go j.listener.serve(j, id, stdin, stdout, exited)
+		}
 		state, _ := j.cmds[id].Process.Wait()
+		close(exited)
 		j.cmds[id].ProcessState = state
+		j.exits[id] = state
+		j.countExit(id, state)
+		j.stoppedAt[id] = time.Now()
 
 		if j.Is(STOPPING, id) {
 			break
 		} else if int(time.Now().Unix())-cur_ts < j.StartSecs {
-			j.SetState(BACKOFF, id)
 			retries++
+			j.retries[id] = retries
+			j.SetState(BACKOFF, id)
 			if j.StartRetries == retries {
 				break
 			}
-			time.Sleep(1 * time.Second)
+			j.backoff(id, retries)
 			continue
 		}
 
 		j.SetState(EXITED, id)
 		retries = 0
+		j.retries[id] = 0
 		if j.Autorestart == AUTORESTART_FALSE {
 			break
 		}
@@ -215,6 +344,12 @@ func (j *Job) startJobWorker(wg *sync.WaitGroup, id int, pgid int) {
 				break
 			}
 		}
+		if !j.allowRestart(id) {
+			j.reasons[id] = fmt.Sprintf("restarted %d times within %ds", j.MaxRestarts, j.RestartWindow)
+			logger.Errorf("[FATAL] Program(name=%s) %s, giving up", j.DisplayName(id), j.reasons[id])
+			j.SetState(FATAL, id)
+			break
+		}
 	}
 	if j.Is(BACKOFF, id) {
 		j.SetState(FATAL, id)
@@ -224,112 +359,248 @@ func (j *Job) startJobWorker(wg *sync.WaitGroup, id int, pgid int) {
 	j._running[id] = false
 }
 
-func (j *Job) setLog(file string, writer *utils.DynamicWriter, _default io.Writer) error {
+// allowRestart records a restart of the process and reports whether it stays
+// within MaxRestarts over the last RestartWindow seconds.
+func (j *Job) allowRestart(id int) bool {
+	if j.MaxRestarts == 0 {
+		return true
+	}
+
+	now := time.Now()
+	window := now.Add(-time.Duration(j.RestartWindow) * time.Second)
+	restarts := slices.DeleteFunc(j.restarts[id], func(t time.Time) bool {
+		return t.Before(window)
+	})
+	j.restarts[id] = append(restarts, now)
+	return len(j.restarts[id]) <= j.MaxRestarts
+}
+
+func (j *Job) ClearRestarts(wg *sync.WaitGroup, _done chan bool, procIds ...int) error {
+	defer func() { _done <- true }()
+
+	for _, i := range j.procIds(procIds) {
+		j.restarts[i] = nil
+		j.reasons[i] = ""
+	}
+	return nil
+}
+
+func (j *Job) backoffDelay(retries int) time.Duration {
+	delay := float64(j.BackoffInitial) * math.Pow(j.BackoffFactor, float64(retries-1))
+	delay = min(delay, float64(j.BackoffMax))
+	delay += delay * j.BackoffJitter * (2*rand.Float64() - 1)
+	return time.Duration(max(delay, 0) * float64(time.Second))
+}
+
+// backoff waits before the next start attempt, giving up early when the
+// process is being stopped.
+func (j *Job) backoff(id int, retries int) {
+	j.nextRetry[id] = time.Now().Add(j.backoffDelay(retries))
+	for time.Now().Before(j.nextRetry[id]) && !j.Is(STOPPING, id) {
+		time.Sleep(min(time.Until(j.nextRetry[id]), 100*time.Millisecond))
+	}
+}
+
+func (j *Job) setLog(file string, maxBytes int, backups int, writer *utils.DynamicWriter, _default io.Writer) error {
+	var old io.Writer
 	if file != "" {
-		file, err := utils.OpenLogFile(file)
+		file, err := utils.OpenRotatingFile(file, int64(maxBytes), backups)
 		if err != nil {
 			return err
 		}
 
-		writer.SetWriter(file)
+		old = writer.SetWriter(file)
 	} else if _default != nil {
-		writer.SetWriter(_default)
+		old = writer.SetWriter(_default)
+	}
+
+	if rotating, ok := old.(*utils.RotatingFile); ok {
+		rotating.Close()
 	}
 	return nil
 }
 
+func (j *Job) setStdoutLog() error {
+	return j.setLog(j.StdoutLogFile, j.StdoutLogMax, j.StdoutLogKeep, j.StdoutWriter, os.Stdout)
+}
+
+func (j *Job) setStderrLog() error {
+	return j.setLog(j.StderrLogFile, j.StderrLogMax, j.StderrLogKeep, j.StderrWriter, os.Stderr)
+}
+
 func (j *Job) tryStart(procId int) error {
-	err := j.setLog(j.StdoutLogFile, j.StdoutWriter, os.Stdout)
+	err := j.setStdoutLog()
+	if err != nil {
+		return err
+	}
+
+	err = j.setStderrLog()
 	if err != nil {
 		return err
 	}
 
-	err = j.setLog(j.StderrLogFile, j.StderrWriter, os.Stderr)
+	err = j.runHook(PRE_START, procId)
 	if err != nil {
 		return err
 	}
 
-	j.cmds[procId].Stdout = j.StdoutWriter
+	// listeners talk over their standard output, piped by the worker
+	if j.listener == nil {
+		j.cmds[procId].Stdout = j.StdoutWriter
+	}
 	if j.RedirectStderr {
 		j.cmds[procId].Stderr = j.StdoutWriter
 	} else {
 		j.cmds[procId].Stderr = j.StderrWriter
 	}
 
-	j.cmds[procId].Env = append(j.Environment, os.Environ()...)
-	j.cmds[procId].Dir = j.Dir
+	cred, account, err := j.credential()
+	if err != nil {
+		return fmt.Errorf("Program(name=%s) credential: %s", j.DisplayName(procId), err)
+	}
+	j.cmds[procId].SysProcAttr.Credential = cred
+	j.cmds[procId].Env = j.environ(procId, account)
+	j.cmds[procId].Dir = config.ExpandProcess(j.Dir, procId)
+
+	cgroup, err := j.enterCgroup(procId)
+	if err != nil {
+		return fmt.Errorf("Program(name=%s) cgroup: %s", j.DisplayName(procId), err)
+	}
+	if cgroup != -1 {
+		defer syscall.Close(cgroup)
+		j.cmds[procId].SysProcAttr.UseCgroupFD = true
+		j.cmds[procId].SysProcAttr.CgroupFD = cgroup
+	}
+
+	gate, err := j.spawn(j.cmds[procId])
+	if err != nil {
+		return err
+	}
 
 	err = j.cmds[procId].Start()
 	if err != nil {
+		if gate != nil {
+			gate.Close()
+			j.cmds[procId].ExtraFiles[0].Close()
+		}
 		return err
 	}
 
-	return nil
+	return j.release(j.cmds[procId], gate)
 }
 
-func (j *Job) Restart(wg *sync.WaitGroup, _done chan bool) error {
+func (j *Job) Restart(wg *sync.WaitGroup, _done chan bool, procIds ...int) error {
 	done := make(chan bool, 1)
 	defer close(done)
-	j.Stop(wg, done)
-	j.Start(wg, _done)
+	j.Stop(wg, done, procIds...)
+	j.Start(wg, _done, procIds...)
 	return nil
 }
 
-func (j *Job) Stop(wg *sync.WaitGroup, _done chan bool) error {
+func (j *Job) signal(procId int, sig syscall.Signal) error {
+	pid := j.Pid(procId)
+	if pid == 0 {
+		return nil
+	}
+	err := syscall.Kill(-pid, sig)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
+
+func (j *Job) isRunning(procIds []int) bool {
+	for _, i := range procIds {
+		if j._running[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *Job) Stop(wg *sync.WaitGroup, _done chan bool, procIds ...int) error {
 	defer func() { _done <- true }()
 	<-j.startReady
 	j.mustop.Lock()
 	defer j.mustop.Unlock()
 
-	if j.HasPgid() {
-		for i := range j.NumProcs {
-			j.SetState(STOPPING, i)
+	ids := make([]int, 0, j.NumProcs)
+	for _, i := range j.procIds(procIds) {
+		if j._running[i] {
+			ids = append(ids, i)
 		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
 
-		err := syscall.Kill(-j.pgid, j.StopSignal)
-		if err != nil {
+	for _, i := range ids {
+		j.SetState(STOPPING, i)
+	}
+	j.runHooks(PRE_STOP, ids...)
+
+	for _, i := range ids {
+		if err := j.signal(i, j.StopSignal); err != nil {
 			return err
 		}
+	}
 
-		cur := time.Now().Unix()
-		for time.Now().Unix()-cur < int64(j.StopWaitSecs) && j.IsRunning() {
-			time.Sleep(100 * time.Millisecond)
-		}
+	cur := time.Now().Unix()
+	for time.Now().Unix()-cur < int64(j.StopWaitSecs) && j.isRunning(ids) {
+		time.Sleep(100 * time.Millisecond)
+	}
 
-		if j.HasPgid() && j.IsRunning() {
-			err = syscall.Kill(-j.pgid, syscall.SIGKILL)
-			if err != nil {
-				return err
-			}
+	for _, i := range ids {
+		if !j._running[i] {
+			continue
 		}
-
-		for j.IsRunning() {
-			time.Sleep(100 * time.Millisecond)
+		if err := j.signal(i, syscall.SIGKILL); err != nil {
+			return err
 		}
 	}
 
-	j.SetPgid(0)
+	for j.isRunning(ids) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	j.runHooks(POST_STOP, ids...)
 	return nil
 }
 
-func (j *Job) Reload(wg *sync.WaitGroup, _done chan bool, prog *config.Program) error {
+func (j *Job) Reload(wg *sync.WaitGroup, _done chan bool, prog *config.Program, cgroupParent string) error {
 	wg.Add(1)
 	defer wg.Done()
 
-	stdoutChanged := j.StdoutLogFile != prog.StdoutLogFile
-	stderrChanged := j.StderrLogFile != prog.StderrLogFile
-	shouldRestart := j.reread(prog)
+	stdoutChanged := j.StdoutLogFile != prog.StdoutLogFile ||
+		j.StdoutLogMax != prog.StdoutLogMaxBytes || j.StdoutLogKeep != prog.StdoutLogBackups
+	stderrChanged := j.StderrLogFile != prog.StderrLogFile ||
+		j.StderrLogMax != prog.StderrLogMaxBytes || j.StderrLogKeep != prog.StderrLogBackups
+	shouldRestart := j.reread(prog) || cgroupParent != j.CgroupParent
 	if shouldRestart && j.IsRunning() {
-		go j.Restart(wg, _done)
+		// processes only leave their cgroup by being started again
+		go func() {
+			done := make(chan bool, 1)
+			defer close(done)
+			j.Stop(wg, done)
+			j.setCgroupParent(cgroupParent)
+			j.Start(wg, _done)
+		}()
 		return nil
 	}
+	j.setCgroupParent(cgroupParent)
 
 	if stdoutChanged {
-		j.setLog(j.StdoutLogFile, j.StdoutWriter, os.Stdout)
+		j.setStdoutLog()
 	}
 
 	if stderrChanged {
-		j.setLog(j.StderrLogFile, j.StderrWriter, os.Stderr)
+		j.setStderrLog()
+	}
+
+	if j.IsRunning() && j.CgroupParent != "" {
+		if err := j.setCgroupLimits(); err != nil {
+			logger.Errorf("Program(name=%s) cgroup: %s", j.Name, err)
+		}
 	}
 
 	_done <- true
@@ -339,8 +610,9 @@ func (j *Job) Reload(wg *sync.WaitGroup, _done chan bool, prog *config.Program)
 func (j *Job) reread(prog *config.Program) bool {
 	shouldRestart := false
 
-	if prog.Command != j.Command {
+	if prog.Command != j.Command || prog.Exec != j.Exec {
 		j.Command = prog.Command
+		j.Exec = prog.Exec
 		shouldRestart = true
 	}
 
@@ -367,11 +639,27 @@ func (j *Job) reread(prog *config.Program) bool {
 
 	}
 
+	if prog.CleanEnvironment != j.CleanEnv {
+		j.CleanEnv = prog.CleanEnvironment
+		shouldRestart = true
+	}
+
+	if prog.User != j.User || prog.Group != j.Group {
+		j.User = prog.User
+		j.Group = prog.Group
+		shouldRestart = true
+	}
+
 	if prog.Umask != j.Umask {
 		j.Umask = prog.Umask
 		shouldRestart = true
 	}
 
+	if limits := rlimits(prog); !slices.Equal(limits, j.Rlimits) {
+		j.Rlimits = limits
+		shouldRestart = true
+	}
+
 	if prog.StderrLogFile != j.StderrLogFile {
 		j.StderrLogFile = prog.StderrLogFile
 	}
@@ -380,15 +668,37 @@ func (j *Job) reread(prog *config.Program) bool {
 		j.StdoutLogFile = prog.StdoutLogFile
 	}
 
+	j.StdoutLogMax = prog.StdoutLogMaxBytes
+	j.StdoutLogKeep = prog.StdoutLogBackups
+	j.StderrLogMax = prog.StderrLogMaxBytes
+	j.StderrLogKeep = prog.StderrLogBackups
+
 	j.Autostart = prog.Autostart
 	j.ExitCodes = prog.ExitCodes
 	j.StopWaitSecs = prog.StopWaitSecs
 	j.StopSignal = utils.ParseSignal(prog.StopSignal)
 	j.Autorestart = prog.Autorestart
+	j.MaxRestarts = prog.MaxRestarts
+	j.RestartWindow = prog.RestartWindow
 	j.StartSecs = prog.StartSecs
 	j.StartRetries = prog.StartRetries
+	j.BackoffInitial = prog.BackoffInitial
+	j.BackoffMax = prog.BackoffMax
+	j.BackoffFactor = prog.BackoffMultiplier
+	j.BackoffJitter = prog.BackoffJitter
 	j.Priority = prog.Priority
 	j.ProcessName = prog.ProcessName
+	j.DependsOn = prog.DependsOn
+	j.WaitDeps = prog.WaitDependencies
+	j.HealthCheck = prog.HealthCheck
+	j.PreStart = prog.PreStart
+	j.PostStart = prog.PostStart
+	j.PreStop = prog.PreStop
+	j.PostStop = prog.PostStop
+	j.HookTimeout = prog.HookTimeout
+	j.MemoryMax = prog.MemoryMax
+	j.CpuMax = prog.CpuMax
+	j.PidsMax = prog.PidsMax
 
 	if prog.RedirectStderr != j.RedirectStderr {
 		j.RedirectStderr = prog.RedirectStderr
@@ -0,0 +1,281 @@
+package job
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Archer-01/taskmaster/internal/events"
+	"github.com/Archer-01/taskmaster/internal/logger"
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+)
+
+const (
+	LISTENER_READY  = "READY"
+	LISTENER_RESULT = "RESULT"
+	RESULT_OK       = "OK"
+	RESULT_FAIL     = "FAIL"
+)
+
+// listener feeds the events it subscribed to to the processes of an event
+// listener job. Each event goes to one READY process; the others are
+// buffered, up to bufferSize, until a process asks for more.
+type listener struct {
+	mu         sync.Mutex
+	name       string
+	filter     events.Filter
+	bufferSize int
+	sub        *events.Subscription
+	buffer     []events.Event
+	out        chan events.Event
+	retry      chan events.Event
+	done       chan struct{}
+	serial     uint64
+}
+
+type listenerReply struct {
+	token string
+	body  string
+}
+
+func NewListener(name string, conf *config.EventListener) *Job {
+	j := NewJob(name, &conf.Program)
+	l := &listener{
+		name:  name,
+		out:   make(chan events.Event),
+		retry: make(chan events.Event),
+		done:  make(chan struct{}),
+	}
+	l.configure(conf)
+	l.sub = events.Subscribe(l.accept)
+	go l.dispatch()
+
+	j.listener = l
+	return j
+}
+
+func (j *Job) IsListener() bool {
+	return j.listener != nil
+}
+
+// ReloadListener applies new events and buffer_size settings; the rest of the
+// program settings go through Reload.
+func (j *Job) ReloadListener(conf *config.EventListener) {
+	j.listener.configure(conf)
+}
+
+// Close stops delivering events to an event listener job.
+func (j *Job) Close() {
+	if j.listener != nil {
+		events.Unsubscribe(j.listener.sub)
+	}
+}
+
+func (l *listener) configure(conf *config.EventListener) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.filter = events.ParseFilter(conf.Events)
+	l.bufferSize = conf.BufferSize
+}
+
+// accept never lets a listener hear about itself, which would keep it busy
+// with its own state changes.
+func (l *listener) accept(e events.Event) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e.Program == l.name {
+		return false
+	}
+	return l.filter == nil || l.filter(e)
+}
+
+func (l *listener) push(e events.Event, front bool) {
+	l.mu.Lock()
+	size := l.bufferSize
+	l.mu.Unlock()
+
+	if front {
+		l.buffer = append([]events.Event{e}, l.buffer...)
+	} else {
+		l.buffer = append(l.buffer, e)
+	}
+
+	if len(l.buffer) > size {
+		dropped := l.buffer[0]
+		l.buffer = l.buffer[1:]
+		logger.Warnf("Listener(name=%s) buffer full, discarding event %d %s", l.name, dropped.Serial, dropped.Type)
+	}
+}
+
+func (l *listener) dispatch() {
+	defer close(l.done)
+
+	for {
+		var out chan events.Event
+		var next events.Event
+		if len(l.buffer) != 0 {
+			out = l.out
+			next = l.buffer[0]
+		}
+
+		select {
+		case e, ok := <-l.sub.C:
+			if !ok {
+				return
+			}
+			l.push(e, false)
+		case e := <-l.retry:
+			l.push(e, true)
+		case out <- next:
+			l.buffer = l.buffer[1:]
+		}
+	}
+}
+
+func (l *listener) requeue(e events.Event) {
+	select {
+	case l.retry <- e:
+	case <-l.done:
+	}
+}
+
+// pipe connects the protocol ends of a listener process, its standard output
+// being reserved for READY and RESULT messages.
+func (l *listener) pipe(cmd *exec.Cmd) (io.WriteCloser, io.ReadCloser, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stdin.Close()
+		return nil, nil, err
+	}
+	return stdin, stdout, nil
+}
+
+// read parses the messages sent by a listener process. Anything outside of
+// the protocol is logged as regular output.
+func (l *listener) read(j *Job, stdout io.Reader, replies chan<- listenerReply, exited chan struct{}) {
+	defer close(replies)
+
+	r := bufio.NewReader(stdout)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		reply := listenerReply{token: strings.TrimSpace(line)}
+		if fields := strings.Fields(reply.token); len(fields) == 2 && fields[0] == LISTENER_RESULT {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 0 {
+				j.StdoutWriter.Write([]byte(line))
+				continue
+			}
+
+			body := make([]byte, n)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			reply = listenerReply{token: LISTENER_RESULT, body: string(body)}
+		} else if reply.token != LISTENER_READY {
+			j.StdoutWriter.Write([]byte(line))
+			continue
+		}
+
+		select {
+		case replies <- reply:
+		case <-exited:
+			return
+		}
+	}
+}
+
+// serve runs the READY / event / RESULT handshake with a listener process
+// until it exits. Events the process fails to handle are buffered again.
+func (l *listener) serve(j *Job, procId int, stdin io.WriteCloser, stdout io.ReadCloser, exited chan struct{}) {
+	defer stdin.Close()
+	defer stdout.Close()
+
+	replies := make(chan listenerReply)
+	go l.read(j, stdout, replies, exited)
+
+	for {
+		select {
+		case reply, ok := <-replies:
+			if !ok {
+				return
+			}
+			if reply.token != LISTENER_READY {
+				logger.Warnf("Listener(name=%s) sent %s while not busy", j.DisplayName(procId), reply.token)
+				continue
+			}
+		case <-exited:
+			return
+		}
+
+		var e events.Event
+		select {
+		case e = <-l.out:
+		case <-exited:
+			return
+		}
+
+		if _, err := io.WriteString(stdin, l.encode(e)); err != nil {
+			l.requeue(e)
+			return
+		}
+
+		select {
+		case reply, ok := <-replies:
+			if !ok {
+				l.requeue(e)
+				return
+			}
+			if reply.token != LISTENER_RESULT || reply.body != RESULT_OK {
+				logger.Warnf("Listener(name=%s) rejected event %d %s", j.DisplayName(procId), e.Serial, e.Type)
+				l.requeue(e)
+			}
+		case <-exited:
+			l.requeue(e)
+			return
+		}
+	}
+}
+
+// encode writes e the way supervisord does: a header line of key:value
+// tokens ending with the length of the payload that follows it.
+func (l *listener) encode(e events.Event) string {
+	payload := make([]string, 0)
+	if e.Name != "" {
+		payload = append(payload,
+			"processname:"+e.Name,
+			"groupname:"+e.Program,
+			"from_state:"+e.FromState,
+			fmt.Sprintf("pid:%d", e.Pid))
+		if e.ExitCode != nil {
+			payload = append(payload, fmt.Sprintf("exitcode:%d", *e.ExitCode))
+		}
+		if e.Signal != "" {
+			payload = append(payload, "signal:"+e.Signal)
+		}
+	}
+	body := strings.Join(payload, " ")
+
+	l.mu.Lock()
+	l.serial++
+	serial := l.serial
+	l.mu.Unlock()
+
+	header := fmt.Sprintf("ver:3.0 server:taskmaster serial:%d pool:%s poolserial:%d eventname:%s len:%d\n",
+		e.Serial, l.name, serial, e.Type, len(body))
+	return header + body
+}
@@ -0,0 +1,65 @@
+package job
+
+import (
+	"maps"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/Archer-01/taskmaster/internal/utils"
+)
+
+// Counters are kept for the whole life of a process slot, across restarts
+// and reloads.
+type Counters struct {
+	Starts uint64
+	Fatals uint64
+	Exits  map[string]uint64
+}
+
+type Metrics struct {
+	Status
+	Counters
+}
+
+func (j *Job) count(procId int, from string, to string) {
+	j.mumetrics.Lock()
+	defer j.mumetrics.Unlock()
+
+	switch {
+	case to == RUNNING && from == STARTING:
+		j.counters[procId].Starts++
+	case to == FATAL:
+		j.counters[procId].Fatals++
+	}
+}
+
+// exitCode names how a process ended: its exit code, or the signal that
+// killed it.
+func exitCode(state *os.ProcessState) string {
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if ok && ws.Signaled() {
+		return utils.SignalName(ws.Signal())
+	}
+	return strconv.Itoa(state.ExitCode())
+}
+
+func (j *Job) countExit(procId int, state *os.ProcessState) {
+	j.mumetrics.Lock()
+	defer j.mumetrics.Unlock()
+
+	counters := &j.counters[procId]
+	if counters.Exits == nil {
+		counters.Exits = make(map[string]uint64)
+	}
+	counters.Exits[exitCode(state)]++
+}
+
+func (j *Job) Metrics(procId int) Metrics {
+	j.mumetrics.Lock()
+	defer j.mumetrics.Unlock()
+
+	counters := j.counters[procId]
+	counters.Exits = maps.Clone(counters.Exits)
+	return Metrics{Status: j.Status(procId), Counters: counters}
+}
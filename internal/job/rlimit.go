@@ -0,0 +1,74 @@
+package job
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+	"golang.org/x/sys/unix"
+)
+
+// RLIMIT_UNSET leaves a limit as inherited from the daemon.
+const RLIMIT_UNSET = -1
+
+// GATE_FD is the descriptor a process waits on until its limits are set.
+const GATE_FD = 3
+
+type Rlimit struct {
+	Name     string
+	Resource int
+	Value    uint64
+}
+
+func rlimits(prog *config.Program) []Rlimit {
+	limits := make([]Rlimit, 0)
+	for _, limit := range []struct {
+		name     string
+		resource int
+		value    int
+	}{
+		{"nofile", unix.RLIMIT_NOFILE, prog.RlimitNofile},
+		{"as", unix.RLIMIT_AS, prog.RlimitAs},
+		{"core", unix.RLIMIT_CORE, prog.RlimitCore},
+		{"nproc", unix.RLIMIT_NPROC, prog.RlimitNproc},
+		{"cpu", unix.RLIMIT_CPU, prog.RlimitCpu},
+		{"fsize", unix.RLIMIT_FSIZE, prog.RlimitFsize},
+		{"data", unix.RLIMIT_DATA, prog.RlimitData},
+		{"stack", unix.RLIMIT_STACK, prog.RlimitStack},
+		{"memlock", unix.RLIMIT_MEMLOCK, prog.RlimitMemlock},
+	} {
+		if limit.value != RLIMIT_UNSET {
+			limits = append(limits, Rlimit{limit.name, limit.resource, uint64(limit.value)})
+		}
+	}
+	return limits
+}
+
+func prlimit(pid int, limit Rlimit) error {
+	rlim := unix.Rlimit{Cur: limit.Value, Max: limit.Value}
+	if err := unix.Prlimit(pid, limit.Resource, &rlim, nil); err != nil {
+		return fmt.Errorf("rlimit_%s: %s", limit.Name, err)
+	}
+	return nil
+}
+
+// release sets the limits of a spawned process and lets it run its command,
+// killing it when a limit cannot be set.
+func (j *Job) release(cmd *exec.Cmd, gate *os.File) error {
+	if gate == nil {
+		return nil
+	}
+	defer gate.Close()
+	cmd.ExtraFiles[0].Close()
+
+	for _, limit := range j.Rlimits {
+		if err := prlimit(cmd.Process.Pid, limit); err != nil {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			cmd.Process.Wait()
+			return fmt.Errorf("Program(name=%s) %s", j.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package job
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+)
+
+// SPAWN_ARG makes the daemon binary run as the spawn helper of a process.
+const SPAWN_ARG = "--taskmaster-spawn"
+
+// SPAWN_FAILED is the exit status of a spawn helper that could not run the
+// command.
+const SPAWN_FAILED = 127
+
+// spawn starts a process through the daemon binary itself when it needs
+// more than exec.Cmd offers: the umask of directly executed commands, which
+// have no shell to set it, and limits set before the command runs. The
+// helper keeps the privileges of the daemon while it waits on GATE_FD for
+// the limits, then sets the umask, switches to the program credential and
+// executes the command. Limits are thus set on a process the daemon owns.
+// The returned gate is nil when there are no limits to wait for.
+func (j *Job) spawn(cmd *exec.Cmd) (*os.File, error) {
+	gated := len(j.Rlimits) != 0
+	if !gated && j.Exec != config.EXEC_DIRECT {
+		return nil, nil
+	}
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+
+	var r, w *os.File
+	if gated {
+		var err error
+		r, w, err = os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		cmd.ExtraFiles = []*os.File{r}
+	}
+
+	cred := ""
+	if c := cmd.SysProcAttr.Credential; c != nil {
+		groups := make([]string, len(c.Groups))
+		for i, gid := range c.Groups {
+			groups[i] = strconv.FormatUint(uint64(gid), 10)
+		}
+		cred = fmt.Sprintf("%d:%d:%s", c.Uid, c.Gid, strings.Join(groups, ","))
+		cmd.SysProcAttr.Credential = nil
+	}
+
+	cmd.Args = append([]string{os.Args[0], SPAWN_ARG, strconv.FormatBool(gated), j.Umask, cred, cmd.Path}, cmd.Args...)
+	cmd.Path = "/proc/self/exe"
+	return w, nil
+}
+
+func setCredential(cred string) error {
+	fields := strings.Split(cred, ":")
+	if len(fields) != 3 {
+		return fmt.Errorf("malformed credential %q", cred)
+	}
+
+	uid, err := parseId(fields[0])
+	if err != nil {
+		return err
+	}
+	gid, err := parseId(fields[1])
+	if err != nil {
+		return err
+	}
+	groups := make([]int, 0)
+	for _, group := range strings.Split(fields[2], ",") {
+		if group == "" {
+			continue
+		}
+		id, err := parseId(group)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, int(id))
+	}
+
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("setgroups: %s", err)
+	}
+	if err := syscall.Setgid(int(gid)); err != nil {
+		return fmt.Errorf("setgid: %s", err)
+	}
+	if err := syscall.Setuid(int(uid)); err != nil {
+		return fmt.Errorf("setuid: %s", err)
+	}
+	return nil
+}
+
+// Spawn is the spawn helper side, args being what follows SPAWN_ARG: whether
+// to wait on GATE_FD, the umask, the credential, the path of the command and
+// its arguments. It never returns.
+func Spawn(args []string) {
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "taskmaster: %s\n", err)
+		os.Exit(SPAWN_FAILED)
+	}
+	if len(args) < 5 {
+		fail(fmt.Errorf("%s expects a gate, a umask, a credential, a path and arguments", SPAWN_ARG))
+	}
+	gated, umask, cred, path, argv := args[0], args[1], args[2], args[3], args[4:]
+
+	if gated == "true" {
+		// the daemon closes its end once the limits are set
+		gate := os.NewFile(GATE_FD, "gate")
+		io.Copy(io.Discard, gate)
+		gate.Close()
+	}
+
+	mask, err := strconv.ParseUint(umask, 8, 32)
+	if err != nil {
+		fail(fmt.Errorf("umask: %s", err))
+	}
+	syscall.Umask(int(mask))
+
+	if cred != "" {
+		if err := setCredential(cred); err != nil {
+			fail(err)
+		}
+	}
+	fail(syscall.Exec(path, argv, os.Environ()))
+}
@@ -2,17 +2,25 @@ package job
 
 import (
 	"fmt"
+	"math"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/Archer-01/taskmaster/internal/events"
+	"github.com/Archer-01/taskmaster/internal/utils"
 )
 
 const (
-	STOPPED  = "STOPPED"
-	STARTING = "STARTING"
-	RUNNING  = "RUNNING"
-	BACKOFF  = "BACKOFF"
-	STOPPING = "STOPPING"
-	EXITED   = "EXITED"
-	FATAL    = "FATAL"
-	UNKNOWN  = "UNKNOWN"
+	STOPPED   = "STOPPED"
+	STARTING  = "STARTING"
+	RUNNING   = "RUNNING"
+	BACKOFF   = "BACKOFF"
+	STOPPING  = "STOPPING"
+	EXITED    = "EXITED"
+	FATAL     = "FATAL"
+	UNHEALTHY = "UNHEALTHY"
+	UNKNOWN   = "UNKNOWN"
 )
 
 const (
@@ -21,38 +29,146 @@ const (
 	AUTORESTART_TRUE       = "true"
 )
 
+var States = []string{STOPPED, STARTING, RUNNING, BACKOFF, STOPPING, EXITED, FATAL, UNHEALTHY, UNKNOWN}
+
 func (j *Job) SetState(state string, procId int) error {
-	switch state {
-	case STARTING, RUNNING, BACKOFF, STOPPING, EXITED, FATAL, UNKNOWN:
-		j.State[procId] = state
-	case STOPPED:
-		j.State[procId] = STOPPED
-		if true {
-			j.pgid = 0
-		}
-	default:
+	if !slices.Contains(States, state) {
 		return fmt.Errorf("invalid state: %s", state)
 	}
+
+	from := j.State[procId]
+	j.State[procId] = state
+	if from != state {
+		j.count(procId, from, state)
+		j.publish(procId, from)
+	}
 	return nil
 }
 
+func (j *Job) publish(procId int, from string) {
+	status := j.status(procId)
+	events.Publish(events.Event{
+		Type:      events.ProcessState(status.State),
+		Program:   j.Name,
+		Name:      status.Name,
+		ProcId:    procId,
+		Pid:       j.Pid(procId),
+		FromState: from,
+		ExitCode:  status.ExitCode,
+		Signal:    status.Signal,
+	})
+}
+
 func (j *Job) Is(state string, procId int) bool {
 	return j.State[procId] == state
 }
 
-func (j *Job) HasPgid() bool {
-	return j.pgid != 0
+func (j *Job) IsRunning() bool {
+	return j.isRunning(j.procIds(nil))
 }
 
-func (j *Job) SetPgid(num int) {
-	j.pgid = num
+// IsHealthy reports whether at least one process is active and every
+// active process has been RUNNING for at least StartSecs.
+func (j *Job) IsHealthy() bool {
+	startSecs := time.Duration(j.StartSecs) * time.Second
+	healthy := false
+	for i := range j.NumProcs {
+		if !j._running[i] {
+			continue
+		}
+		if !j.Is(RUNNING, i) || time.Since(j.startedAt[i]) < startSecs {
+			return false
+		}
+		healthy = true
+	}
+	return healthy
 }
 
-func (j *Job) IsRunning() bool {
-	for i := range j.NumProcs {
-		if j._running[i] {
-			return true
+func (j *Job) WaitHealthy() error {
+	for !j.IsHealthy() {
+		if !j.IsRunning() {
+			return fmt.Errorf("%s is not running", j.Name)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
+
+type Status struct {
+	Name         string  `json:"name"`
+	Program      string  `json:"program"`
+	ProcId       int     `json:"proc_id"`
+	State        string  `json:"state"`
+	Pid          int     `json:"pid,omitempty"`
+	Uptime       int64   `json:"uptime,omitempty"`
+	StartSecs    int     `json:"startsecs,omitempty"`
+	SinceExit    int64   `json:"since_exit,omitempty"`
+	ExitCode     *int    `json:"exit_code,omitempty"`
+	Signal       string  `json:"signal,omitempty"`
+	Retries      int     `json:"retries,omitempty"`
+	StartRetries int     `json:"start_retries,omitempty"`
+	NextRetry    int64   `json:"next_retry,omitempty"`
+	Failures     int     `json:"health_failures,omitempty"`
+	Reason       string  `json:"reason,omitempty"`
+	Memory       int64   `json:"memory,omitempty"`
+	CpuTime      float64 `json:"cpu_time,omitempty"`
+	OomKills     int64   `json:"oom_kills,omitempty"`
+}
+
+func (j *Job) Status(procId int) Status {
+	status := j.status(procId)
+	j.cgroupStatus(procId, &status)
+	return status
+}
+
+// status holds what the job knows about a process without asking the system.
+func (j *Job) status(procId int) Status {
+	status := Status{
+		Name:    j.DisplayName(procId),
+		Program: j.Name,
+		ProcId:  procId,
+		State:   j.State[procId],
+	}
+
+	switch status.State {
+	case STARTING, RUNNING, UNHEALTHY, STOPPING:
+		status.Pid = j.Pid(procId)
+		status.Failures = j.healthFailures[procId]
+		status.StartSecs = j.StartSecs
+		if !j.startedAt[procId].IsZero() {
+			status.Uptime = int64(time.Since(j.startedAt[procId]).Seconds())
+		}
+	case BACKOFF:
+		status.Retries = j.retries[procId]
+		status.StartRetries = j.StartRetries
+		if wait := time.Until(j.nextRetry[procId]); wait > 0 {
+			status.NextRetry = int64(math.Ceil(wait.Seconds()))
 		}
+		j.exitStatus(procId, &status)
+	case EXITED, FATAL, STOPPED:
+		status.Reason = j.reasons[procId]
+		j.exitStatus(procId, &status)
 	}
-	return false
+	return status
+}
+
+func (j *Job) exitStatus(procId int, status *Status) {
+	if j.stoppedAt[procId].IsZero() {
+		return
+	}
+	status.SinceExit = int64(time.Since(j.stoppedAt[procId]).Seconds())
+
+	state := j.exits[procId]
+	if state == nil {
+		return
+	}
+
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if ok && ws.Signaled() {
+		status.Signal = utils.SignalName(ws.Signal())
+		return
+	}
+
+	code := state.ExitCode()
+	status.ExitCode = &code
 }
@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"slices"
+
+	"github.com/Archer-01/taskmaster/internal/job"
+	"github.com/Archer-01/taskmaster/internal/logger"
+)
+
+// orderByDependencies moves every job after the jobs it depends on while
+// keeping the given order otherwise. Dependencies outside jobs are ignored.
+func orderByDependencies(jobs []*job.Job) []*job.Job {
+	pending := make(map[string]bool, len(jobs))
+	for _, j := range jobs {
+		pending[j.Name] = true
+	}
+
+	ordered := make([]*job.Job, 0, len(jobs))
+	for len(ordered) != len(jobs) {
+		for _, j := range jobs {
+			if !pending[j.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range j.DependsOn {
+				if pending[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				pending[j.Name] = false
+				ordered = append(ordered, j)
+				break
+			}
+		}
+	}
+	return ordered
+}
+
+// blockers returns the names of the jobs in batch that must be done before
+// j: the jobs depending on it when stopping, its dependencies otherwise.
+// Starting jobs also wait for the job before them, batch being in priority
+// order.
+func blockers(j *job.Job, batch []*job.Job, state string) []string {
+	switch state {
+	case "STOPPING":
+		names := make([]string, 0)
+		for _, other := range batch {
+			if slices.Contains(other.DependsOn, j.Name) {
+				names = append(names, other.Name)
+			}
+		}
+		return names
+
+	case "STARTING", "RESTARTING":
+		names := slices.Clone(j.DependsOn)
+		if i := slices.Index(batch, j); i > 0 {
+			names = append(names, batch[i-1].Name)
+		}
+		return names
+	}
+	return j.DependsOn
+}
+
+// dependencies returns the jobs j waits for before starting. They are looked
+// up before any waiting, as m.Jobs belongs to the action loop.
+func (m *JobManager) dependencies(j *job.Job) []*job.Job {
+	deps := make([]*job.Job, 0)
+	if !j.WaitDeps {
+		return deps
+	}
+
+	for _, name := range j.DependsOn {
+		if dep, found := m.Jobs[name]; found {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+func waitDependencies(j *job.Job, deps []*job.Job) error {
+	for _, dep := range deps {
+		logger.Infof("[WAITING] Program(name=%s) for %s", j.Name, dep.Name)
+		if err := dep.WaitHealthy(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runOrdered runs worker on every job concurrently, except that a job waits
+// for the jobs blocking it to be done first.
+func (m *JobManager) runOrdered(jobs []*job.Job, worker job.WorkerFn, state string) []chan bool {
+	starting := state == "STARTING" || state == "RESTARTING"
+
+	finished := make(map[string]chan struct{}, len(jobs))
+	for _, j := range jobs {
+		finished[j.Name] = make(chan struct{})
+	}
+
+	jobs_done := make([]chan bool, 0, len(jobs))
+	for _, j := range jobs {
+		_done := make(chan bool, 1)
+		jobs_done = append(jobs_done, _done)
+		after := blockers(j, jobs, state)
+		deps := m.dependencies(j)
+
+		go func() {
+			defer close(finished[j.Name])
+			for _, name := range after {
+				if ch, found := finished[name]; found {
+					<-ch
+				}
+			}
+
+			if starting {
+				if err := waitDependencies(j, deps); err != nil {
+					logger.Errorf("Program(name=%s) not started: %s", j.Name, err)
+					_done <- true
+					return
+				}
+				// jobs still waiting at boot must not start once quitting
+				select {
+				case <-m.quitting:
+					_done <- true
+					return
+				default:
+				}
+			}
+
+			logger.Infof("[%s] Program(name=%s)", state, j.Name)
+			worker(j, m.wg, _done)
+		}()
+	}
+	return jobs_done
+}
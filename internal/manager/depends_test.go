@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Archer-01/taskmaster/internal/job"
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+)
+
+func TestOrderByDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		jobs []string
+		deps map[string][]string
+		want []string
+	}{
+		{
+			name: "no dependencies keep their order",
+			jobs: []string{"c", "a", "b"},
+			want: []string{"c", "a", "b"},
+		},
+		{
+			name: "dependency moved first",
+			jobs: []string{"web", "db"},
+			deps: map[string][]string{"web": {"db"}},
+			want: []string{"db", "web"},
+		},
+		{
+			name: "chain",
+			jobs: []string{"a", "b", "c"},
+			deps: map[string][]string{"a": {"b"}, "b": {"c"}},
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "diamond",
+			jobs: []string{"app", "cache", "db", "log"},
+			deps: map[string][]string{"app": {"cache", "db"}, "cache": {"log"}, "db": {"log"}},
+			want: []string{"log", "cache", "db", "app"},
+		},
+		{
+			name: "dependencies outside the jobs ignored",
+			jobs: []string{"web", "worker"},
+			deps: map[string][]string{"web": {"db"}, "worker": {"web"}},
+			want: []string{"web", "worker"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := make([]*job.Job, 0, len(tt.jobs))
+			for _, name := range tt.jobs {
+				jobs = append(jobs, job.NewJob(name, &config.Program{DependsOn: tt.deps[name]}))
+			}
+
+			got := make([]string, 0, len(jobs))
+			for _, j := range orderByDependencies(jobs) {
+				got = append(got, j.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("orderByDependencies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockers(t *testing.T) {
+	tests := []struct {
+		name  string
+		state string
+		job   string
+		want  []string
+	}{
+		{name: "first to start", state: "STARTING", job: "db", want: []string{}},
+		{name: "start after the previous job", state: "STARTING", job: "cache", want: []string{"db"}},
+		{name: "start after dependencies", state: "STARTING", job: "web", want: []string{"db", "cache"}},
+		{name: "restart in order", state: "RESTARTING", job: "cache", want: []string{"db"}},
+		{name: "stop after dependents", state: "STOPPING", job: "db", want: []string{"web"}},
+		{name: "stop without dependents", state: "STOPPING", job: "web", want: []string{}},
+		{name: "clear after dependencies", state: "CLEARING", job: "cache", want: nil},
+	}
+
+	batch := []*job.Job{
+		job.NewJob("db", &config.Program{}),
+		job.NewJob("cache", &config.Program{}),
+		job.NewJob("web", &config.Program{DependsOn: []string{"db"}}),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := slices.IndexFunc(batch, func(j *job.Job) bool { return j.Name == tt.job })
+			if got := blockers(batch[i], batch, tt.state); !slices.Equal(got, tt.want) {
+				t.Errorf("blockers(%s, %s) = %v, want %v", tt.job, tt.state, got, tt.want)
+			}
+		})
+	}
+}
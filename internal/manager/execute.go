@@ -2,15 +2,42 @@ package manager
 
 import (
 	"fmt"
+
+	"github.com/Archer-01/taskmaster/internal/job"
 )
 
 const (
-	STATUS = "status"
+	STATUS    = "status"
+	SUBSCRIBE = "subscribe"
 )
 
+const (
+	ERR_BAD_ARGUMENTS   = "BAD_ARGUMENTS"
+	ERR_NOT_FOUND       = "NOT_FOUND"
+	ERR_UNKNOWN_COMMAND = "UNKNOWN_COMMAND"
+	ERR_FAILED          = "FAILED"
+)
+
+type Error struct {
+	Code    string
+	Message string
+}
+
+func NewError(code string, format string, a ...any) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
 type Response struct {
-	Data string
-	Err  error
+	Data   string
+	Status []job.Status
+	Err    error
 }
 
 func NewResponse() *Response {
@@ -27,6 +54,14 @@ func NewResponseWithBody(data string) *Response {
 	}
 }
 
+func NewStatusResponse(status []job.Status) *Response {
+	return &Response{
+		Data:   formatStatus(status),
+		Status: status,
+		Err:    nil,
+	}
+}
+
 func BadRequest(err error) *Response {
 	return &Response{
 		Data: "",
@@ -42,27 +77,36 @@ func (m *JobManager) Execute(action string, args ...string) *Response {
 	done := make(chan bool, 1)
 	defer close(done)
 
-	data := make(chan string, 1)
-	defer close(data)
+	errs := make(chan *Error, 1)
+	defer close(errs)
 
 	switch action {
-	case QUIT, RELOAD, START, STOP, RESTART:
-		m.actions <- Action{Type: action, Done: done, Data: data, Args: args}
+	case QUIT, RELOAD, START, STOP, RESTART, CLEAR:
+		m.actions <- Action{Type: action, Done: done, Err: errs, Args: args}
 		success := <-done
 		if success {
 			return NewResponse()
 		} else {
-			return BadRequest(fmt.Errorf(<-data))
+			return BadRequest(<-errs)
 		}
 
 	case STATUS:
-		m.actions <- Action{Type: action, Done: done, Data: data, Args: args}
+		status := make(chan []job.Status, 1)
+		defer close(status)
+
+		m.actions <- Action{Type: action, Done: done, Err: errs, Status: status, Args: args}
 		success := <-done
 		if success {
-			return NewResponseWithBody(<-data)
+			return NewStatusResponse(<-status)
 		} else {
-			return BadRequest(fmt.Errorf(<-data))
+			return BadRequest(<-errs)
 		}
+
+	case TAIL:
+		return m.tail(args)
+
+	case SUBSCRIBE:
+		return BadRequest(NewError(ERR_BAD_ARGUMENTS, "%s needs a streaming connection", action))
 	}
-	return BadRequest(fmt.Errorf("%s Unknown command", action))
+	return BadRequest(NewError(ERR_UNKNOWN_COMMAND, "%s Unknown command", action))
 }
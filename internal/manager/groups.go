@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"strings"
+
+	"github.com/Archer-01/taskmaster/internal/job"
+	"github.com/Archer-01/taskmaster/internal/logger"
+	"github.com/Archer-01/taskmaster/internal/parser/config"
+)
+
+const (
+	GROUP_SUFFIX = ":*"
+)
+
+// findGroup reports whether target uses the "name:*" syntax, along with the
+// group it designates, which is nil when no such group exists.
+func (m *JobManager) findGroup(target string) (*config.Group, bool) {
+	name, found := strings.CutSuffix(target, GROUP_SUFFIX)
+	if !found {
+		return nil, false
+	}
+	return m.Groups[name], true
+}
+
+func (m *JobManager) groupJobs(group *config.Group, reverse bool) []*job.Job {
+	jobs := make([]*job.Job, 0, len(group.Programs))
+	for _, name := range group.Programs {
+		if j, found := m.Jobs[name]; found {
+			jobs = append(jobs, j)
+		}
+	}
+	return m.sortJobs(jobs, reverse)
+}
+
+func (m *JobManager) priority(j *job.Job) int {
+	for _, group := range m.Groups {
+		for _, name := range group.Programs {
+			if name == j.Name {
+				return group.Priority
+			}
+		}
+	}
+	return j.Priority
+}
+
+func (m *JobManager) reloadGroups(groups map[string]*config.Group) {
+	for name := range m.Groups {
+		if _, found := groups[name]; !found {
+			logger.Infof("[REMOVED] Group(name=%s)", name)
+		}
+	}
+	for name := range groups {
+		if _, found := m.Groups[name]; !found {
+			logger.Infof("[ADDED] Group(name=%s)", name)
+		}
+	}
+	m.Groups = groups
+}
@@ -3,9 +3,13 @@ package manager
 import (
 	"fmt"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
+	"github.com/Archer-01/taskmaster/internal/events"
 	"github.com/Archer-01/taskmaster/internal/job"
 	"github.com/Archer-01/taskmaster/internal/logger"
 	"github.com/Archer-01/taskmaster/internal/parser/config"
@@ -18,29 +22,41 @@ const (
 	START   = "start"
 	STOP    = "stop"
 	RESTART = "restart"
+	CLEAR   = "clear"
 	ALL     = "all"
 )
 
 type Action struct {
-	Type string
-	Args []string
-	Data chan string
-	Done chan bool
+	Type   string
+	Args   []string
+	Status chan []job.Status
+	Tail   chan TailSource
+	Err    chan *Error
+	Done   chan bool
+}
+
+func (a Action) fail(code string, format string, args ...any) {
+	a.Err <- NewError(code, format, args...)
+	a.Done <- false
 }
 
 type JobManager struct {
-	Jobs    map[string]*job.Job
-	Config  string
-	actions chan Action
-	sigs    chan os.Signal
-	wg      *sync.WaitGroup
+	Jobs     map[string]*job.Job
+	mujobs   sync.RWMutex // held by the action loop to change Jobs
+	Groups   map[string]*config.Group
+	Config   string
+	actions  chan Action
+	sigs     chan os.Signal
+	quitting chan struct{}
+	wg       *sync.WaitGroup
 }
 
 func NewJobManager(path string, wg *sync.WaitGroup) *JobManager {
 	return &JobManager{
-		Config:  path,
-		actions: make(chan Action, 1),
-		wg:      wg,
+		Config:   path,
+		actions:  make(chan Action, 1),
+		quitting: make(chan struct{}),
+		wg:       wg,
 	}
 }
 
@@ -54,7 +70,7 @@ func (m *JobManager) Init() error {
 		logger.Infof("De-escalating privilege to user %s", conf.User)
 
 		if err := utils.DeEscalatePrivilege(conf.User); err != nil {
-			logger.Critical(err)
+			logger.Criticalf("Privilege de-escalation failed: %s", err)
 			os.Exit(1)
 		}
 
@@ -64,14 +80,23 @@ func (m *JobManager) Init() error {
 	logger.Infof("taskmasterd started with pid %d", os.Getpid())
 
 	jobs := make(map[string]*job.Job, 1)
-	for name, prog := range conf.Programs {
+	for name := range conf.AllPrograms() {
+		if name == ALL {
+			return fmt.Errorf("all is a special name, please use another name")
+		}
+		jobs[name] = createJob(name, &conf)
+	}
+
+	for name := range conf.Groups {
 		if name == ALL {
 			return fmt.Errorf("all is a special name, please use another name")
 		}
-		jobs[name] = job.NewJob(name, prog)
 	}
 
+	m.mujobs.Lock()
 	m.Jobs = jobs
+	m.mujobs.Unlock()
+	m.Groups = conf.Groups
 	return nil
 }
 
@@ -82,26 +107,25 @@ func (m *JobManager) reload() error {
 	}
 
 	if conf.User != "" {
-		fmt.Printf("[NOTICE] De-escalating privilege to user %v\n", conf.User)
-
 		if err := utils.DeEscalatePrivilege(conf.User); err != nil {
-			utils.Errorf(err.Error())
-			os.Exit(1)
+			return fmt.Errorf("cannot run as user %s: %s", conf.User, err)
 		}
-
-		fmt.Println("[NOTICE] De-escalation successful")
 	}
 
-	stop := make([]chan bool, 0)
+	programs := conf.AllPrograms()
+	removed := make([]*job.Job, 0)
 	for name, j := range m.Jobs {
-		_, fd := conf.Programs[name]
-		if !fd {
-			d := make(chan bool, 1)
-			stop = append(stop, d)
-			go j.Stop(m.wg, d)
+		_, fd := programs[name]
+		_, listener := conf.Listeners[name]
+		if !fd || listener != j.IsListener() {
+			j.Close()
+			removed = append(removed, j)
+			m.mujobs.Lock()
 			delete(m.Jobs, name)
+			m.mujobs.Unlock()
 		}
 	}
+	stop := m.runOrdered(removed, (*job.Job).Stop, "STOPPING")
 
 	type newJob struct {
 		name string
@@ -110,35 +134,40 @@ func (m *JobManager) reload() error {
 	newJobs := make([]newJob, 0)
 	reloadJobs := make([]chan bool, 0)
 
-	for name, prog := range conf.Programs {
+	for name, prog := range programs {
 		j, fd := m.Jobs[name]
 		d := make(chan bool, 1)
 
 		if fd {
+			if j.IsListener() {
+				j.ReloadListener(conf.Listeners[name])
+			}
 			reloadJobs = append(reloadJobs, d)
-			go j.Reload(m.wg, d, prog)
+			go j.Reload(m.wg, d, prog, conf.CgroupParent)
 		} else {
 			newJobs = append(newJobs, newJob{name: name, prog: prog})
 		}
 	}
 
-	sort.Slice(newJobs, func(i, k int) bool {
-		return newJobs[i].prog.Priority < newJobs[k].prog.Priority
-	})
+	m.reloadGroups(conf.Groups)
 
-	start := make([]chan bool, 0)
+	added := make([]*job.Job, 0, len(newJobs))
 	for _, nj := range newJobs {
-		d := make(chan bool, 1)
-		start = append(start, d)
-		j := job.NewJob(nj.name, nj.prog)
+		j := createJob(nj.name, &conf)
+		m.mujobs.Lock()
 		m.Jobs[nj.name] = j
-		go j.Start(m.wg, d)
+		m.mujobs.Unlock()
+		added = append(added, j)
 	}
+	start := m.runOrdered(m.sortJobs(added, true), (*job.Job).Start, "STARTING")
 
 	for _, _done := range stop {
 		defer close(_done)
 		<-_done
 	}
+	for _, j := range removed {
+		j.RemoveCgroup()
+	}
 	for _, _done := range reloadJobs {
 		defer close(_done)
 		<-_done
@@ -151,57 +180,86 @@ func (m *JobManager) reload() error {
 	return nil
 }
 
+func createJob(name string, conf *config.Config) *job.Job {
+	var j *job.Job
+	if listener, found := conf.Listeners[name]; found {
+		j = job.NewListener(name, listener)
+	} else {
+		j = job.NewJob(name, conf.Programs[name])
+	}
+	j.CgroupParent = conf.CgroupParent
+	return j
+}
+
 func (m *JobManager) sortedJobs(reverse bool) []*job.Job {
 	jobs := make([]*job.Job, 0, len(m.Jobs))
 	for _, j := range m.Jobs {
 		jobs = append(jobs, j)
 	}
+	return m.sortJobs(jobs, reverse)
+}
+
+// sortJobs orders jobs by priority, highest first when reverse is set, and
+// makes dependencies come first in that order and last in the other.
+func (m *JobManager) sortJobs(jobs []*job.Job, reverse bool) []*job.Job {
 	sort.Slice(jobs, func(i, k int) bool {
-		if reverse {
-			return jobs[i].Priority > jobs[k].Priority
+		pi, pk := m.priority(jobs[i]), m.priority(jobs[k])
+		if pi == pk {
+			pi, pk = jobs[i].Priority, jobs[k].Priority
 		}
-		return jobs[i].Priority < jobs[k].Priority
+		return pi > pk
 	})
+
+	jobs = orderByDependencies(jobs)
+	if !reverse {
+		slices.Reverse(jobs)
+	}
 	return jobs
 }
 
+// start launches the autostart jobs without waiting for them, so that the
+// action loop keeps serving while jobs wait for their dependencies.
 func (m *JobManager) start() {
-	var done chan bool
-
+	jobs := make([]*job.Job, 0, len(m.Jobs))
 	for _, j := range m.sortedJobs(true) {
-		if !j.Autostart {
-			continue
+		if j.Autostart {
+			jobs = append(jobs, j)
 		}
-
-		done = make(chan bool, 1)
-		defer close(done)
-		logger.Infof("[STARTING] Program(name=%s)", j.Name)
-		j.Start(m.wg, done)
-		<-done
 	}
+
+	jobs_done := m.runOrdered(jobs, (*job.Job).Start, "STARTING")
+	go func() {
+		for _, _done := range jobs_done {
+			<-_done
+			close(_done)
+		}
+	}()
 }
 
 func (m *JobManager) Run() {
 	m.start()
+	events.Publish(events.Event{Type: events.SUPERVISOR_STATE_RUNNING})
 	for {
 		action := <-m.actions
 		switch action.Type {
 
 		case QUIT:
+			events.Publish(events.Event{Type: events.SUPERVISOR_STATE_STOPPING})
+			close(m.quitting)
 			m.stop()
 			logger.Info("Quitting...")
 			m.finish()
 			action.Done <- true
 			return
 
-	case RELOAD:
-		logger.Warn("Reloading...")
-		if err := m.reload(); err != nil {
-			action.Data <- err.Error()
-			action.Done <- false
-		} else {
-			action.Done <- true
-		}
+		case RELOAD:
+			logger.Warn("Reloading...")
+			events.Publish(events.Event{Type: events.SUPERVISOR_RELOAD})
+			if err := m.reload(); err != nil {
+				action.fail(ERR_FAILED, "%s", err)
+			} else {
+				action.Done <- true
+			}
 
 		case START:
 			m.setJobs("STARTING", (*job.Job).Start, action)
@@ -212,28 +270,37 @@ func (m *JobManager) Run() {
 		case RESTART:
 			m.setJobs("RESTARTING", (*job.Job).Restart, action)
 
+		case CLEAR:
+			m.setJobs("CLEARING", (*job.Job).ClearRestarts, action)
+
 		case STATUS:
 			m.getStatus(action)
 
+		case TAIL:
+			m.getTailSource(action)
+
 		default:
-			action.Data <- "unknown command " + action.Type
-			action.Done <- false
+			action.fail(ERR_UNKNOWN_COMMAND, "unknown command %s", action.Type)
 		}
 	}
 }
 
-func (m *JobManager) runWorkerJob(j *job.Job, worker job.WorkerFn, done chan bool, state string) {
-	logger.Infof("[%s] Program(name=%s)", state, j.Name)
-	go worker(j, m.wg, done)
+func (m *JobManager) runWorkerJob(j *job.Job, worker job.WorkerFn, done chan bool, state string, procIds ...int) {
+	if len(procIds) == 0 {
+		logger.Infof("[%s] Program(name=%s)", state, j.Name)
+	}
+	for _, id := range procIds {
+		logger.Infof("[%s] Program(name=%s)", state, j.DisplayName(id))
+	}
+	go func() {
+		if err := worker(j, m.wg, done, procIds...); err != nil {
+			logger.Errorf("[%s] Program(name=%s) %s", state, j.Name, err)
+		}
+	}()
 }
 
 func (m *JobManager) runWorkerJobs(jobs []*job.Job, worker job.WorkerFn, action Action, state string) {
-	jobs_done := []chan bool{}
-	for _, j := range jobs {
-		_done := make(chan bool, 1)
-		jobs_done = append(jobs_done, _done)
-		m.runWorkerJob(j, worker, _done, state)
-	}
+	jobs_done := m.runOrdered(jobs, worker, state)
 	for _, _done := range jobs_done {
 		defer close(_done)
 		<-_done
@@ -241,21 +308,53 @@ func (m *JobManager) runWorkerJobs(jobs []*job.Job, worker job.WorkerFn, action
 	action.Done <- true
 }
 
+// findTarget resolves a program name, a "name:index" pair or a process
+// display name to its job and the process ids it designates.
+func (m *JobManager) findTarget(target string) (*job.Job, []int, *Error) {
+	if j, found := m.Jobs[target]; found {
+		return j, nil, nil
+	}
+
+	for _, j := range m.Jobs {
+		for i := range j.NumProcs {
+			if j.DisplayName(i) == target {
+				return j, []int{i}, nil
+			}
+		}
+	}
+
+	if sep := strings.LastIndex(target, ":"); sep != -1 {
+		if j, found := m.Jobs[target[:sep]]; found {
+			id, err := strconv.Atoi(target[sep+1:])
+			if err != nil || id < 0 || id >= j.NumProcs {
+				return nil, nil, NewError(ERR_NOT_FOUND, "process index out of range")
+			}
+			return j, []int{id}, nil
+		}
+	}
+	return nil, nil, NewError(ERR_NOT_FOUND, "job is not recognized")
+}
+
 func (m *JobManager) setJobs(state string, worker job.WorkerFn, action Action) {
 	if len(action.Args) != 1 {
-		action.Data <- "command accepts 1 argument only"
-		action.Done <- false
+		action.fail(ERR_BAD_ARGUMENTS, "command accepts 1 argument only")
 		return
 	}
 	name := action.Args[0]
-	if name != ALL {
-		j, found := m.Jobs[name]
-		if !found {
-			action.Data <- "job is not recognized"
+	if group, found := m.findGroup(name); found {
+		if group == nil {
+			action.fail(ERR_NOT_FOUND, "group is not recognized")
+			return
+		}
+		m.runWorkerJobs(m.groupJobs(group, state != "STOPPING"), worker, action, state)
+	} else if name != ALL {
+		j, ids, err := m.findTarget(name)
+		if err != nil {
+			action.Err <- err
 			action.Done <- false
 			return
 		}
-		m.runWorkerJob(j, worker, action.Done, state)
+		m.runWorkerJob(j, worker, action.Done, state, ids...)
 	} else {
 		var sorted []*job.Job
 		if state == "STOPPING" {
@@ -267,15 +366,107 @@ func (m *JobManager) setJobs(state string, worker job.WorkerFn, action Action) {
 	}
 }
 
-func getStatusFmt(j *job.Job) string {
-	if j.NumProcs == 1 {
-		return fmt.Sprintf("[%s]: %s", j.DisplayName(0), j.State[0])
+func jobStatus(j *job.Job, procIds ...int) []job.Status {
+	if len(procIds) == 0 {
+		for i := range j.NumProcs {
+			procIds = append(procIds, i)
+		}
+	}
+
+	status := make([]job.Status, 0, len(procIds))
+	for _, i := range procIds {
+		status = append(status, j.Status(i))
+	}
+	return status
+}
+
+func formatDuration(secs int64) string {
+	return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
+
+func describeExit(st job.Status) string {
+	desc := ""
+	if st.Signal != "" {
+		desc = "killed by signal " + st.Signal
+	} else if st.ExitCode != nil {
+		desc = fmt.Sprintf("exit code %d", *st.ExitCode)
+	}
+	if st.State != job.BACKOFF {
+		ago := fmt.Sprintf("exited %s ago", formatDuration(st.SinceExit))
+		if desc == "" {
+			return ago
+		}
+		desc = ago + ", " + desc
+	}
+	return desc
+}
+
+func describeStatus(st job.Status) string {
+	switch st.State {
+	case job.STARTING, job.RUNNING, job.UNHEALTHY, job.STOPPING:
+		if st.Pid == 0 {
+			return ""
+		}
+		desc := fmt.Sprintf("pid %d, uptime %s", st.Pid, formatDuration(st.Uptime))
+		if st.Memory != 0 {
+			desc += ", memory " + formatBytes(st.Memory)
+		}
+		if st.CpuTime != 0 {
+			desc += fmt.Sprintf(", cpu %.2fs", st.CpuTime)
+		}
+		if st.Failures != 0 {
+			desc += fmt.Sprintf(", failed health checks: %d", st.Failures)
+		}
+		if st.OomKills != 0 {
+			desc += fmt.Sprintf(", oom kills: %d", st.OomKills)
+		}
+		return desc
+	case job.BACKOFF:
+		desc := fmt.Sprintf("retry %d of %d", st.Retries, st.StartRetries)
+		if st.NextRetry != 0 {
+			desc += fmt.Sprintf(" in %s", formatDuration(st.NextRetry))
+		}
+		if exit := describeExit(st); exit != "" {
+			desc += ", " + exit
+		}
+		return desc
+	case job.EXITED, job.FATAL, job.STOPPED:
+		parts := make([]string, 0, 3)
+		if st.Reason != "" {
+			parts = append(parts, st.Reason)
+		}
+		if st.SinceExit != 0 || st.ExitCode != nil || st.Signal != "" {
+			parts = append(parts, describeExit(st))
+		}
+		if st.OomKills != 0 {
+			parts = append(parts, fmt.Sprintf("oom kills: %d", st.OomKills))
+		}
+		return strings.Join(parts, ", ")
 	}
+	return ""
+}
 
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[prefix])
+}
+
+func formatStatus(status []job.Status) string {
 	msg := ""
-	for i := range j.NumProcs {
-		msg += fmt.Sprintf("[%s]: %s", j.DisplayName(i), j.State[i])
-		if i != j.NumProcs-1 {
+	for i, st := range status {
+		msg += fmt.Sprintf("[%s]: %s", st.Name, st.State)
+		if desc := describeStatus(st); desc != "" {
+			msg += " (" + desc + ")"
+		}
+		if i != len(status)-1 {
 			msg += "\n"
 		}
 	}
@@ -284,34 +475,40 @@ func getStatusFmt(j *job.Job) string {
 
 func (m *JobManager) getStatus(action Action) {
 	if len(action.Args) != 1 {
-		action.Data <- "command accepts 1 argument only"
-		action.Done <- false
+		action.fail(ERR_BAD_ARGUMENTS, "command accepts 1 argument only")
 		return
 	}
 
 	name := action.Args[0]
-	if name != ALL {
-		j, found := m.Jobs[name]
-		if !found {
-			action.Data <- "job is not recognized"
+	if group, found := m.findGroup(name); found {
+		if group == nil {
+			action.fail(ERR_NOT_FOUND, "group is not recognized")
+			return
+		}
+		status := make([]job.Status, 0)
+		for _, j := range m.groupJobs(group, true) {
+			status = append(status, jobStatus(j)...)
+		}
+		action.Status <- status
+		action.Done <- true
+
+	} else if name != ALL {
+		j, ids, err := m.findTarget(name)
+		if err != nil {
+			action.Err <- err
 			action.Done <- false
 			return
 		}
-		action.Data <- getStatusFmt(j)
+		action.Status <- jobStatus(j, ids...)
 		action.Done <- true
 
 	} else {
-		msg := ""
-		i := 0
+		status := make([]job.Status, 0)
 		for _, j := range m.Jobs {
-			msg += getStatusFmt(j)
-			i++
-			if i != len(m.Jobs) {
-				msg += "\n"
-			}
+			status = append(status, jobStatus(j)...)
 		}
 
-		action.Data <- msg
+		action.Status <- status
 		action.Done <- true
 	}
 }
@@ -326,9 +523,16 @@ func (m *JobManager) stop() {
 		logger.Infof("[STOPPING] Program(name=%s)", j.Name)
 		j.Stop(m.wg, done)
 		<-done
+		j.RemoveCgroup()
 	}
 }
 
+// finish fails the actions sent once the daemon quits rather than closing
+// the channel under the servers still sending on it.
 func (m *JobManager) finish() {
-	close(m.actions)
+	go func() {
+		for action := range m.actions {
+			action.fail(ERR_FAILED, "taskmasterd is shutting down")
+		}
+	}()
 }
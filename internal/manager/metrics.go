@@ -0,0 +1,30 @@
+package manager
+
+import (
+	"sort"
+
+	"github.com/Archer-01/taskmaster/internal/job"
+)
+
+// Metrics returns the state and counters of every managed process. It reads
+// the jobs directly rather than through the action loop, so that scrapes go
+// on while the loop is busy stopping, reloading or starting jobs.
+func (m *JobManager) Metrics() []job.Metrics {
+	m.mujobs.RLock()
+	defer m.mujobs.RUnlock()
+
+	names := make([]string, 0, len(m.Jobs))
+	for name := range m.Jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]job.Metrics, 0)
+	for _, name := range names {
+		j := m.Jobs[name]
+		for i := range j.NumProcs {
+			metrics = append(metrics, j.Metrics(i))
+		}
+	}
+	return metrics
+}
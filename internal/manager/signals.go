@@ -34,12 +34,24 @@ func (m *JobManager) WaitForSignals(wg *sync.WaitGroup) {
 		switch sig {
 
 		case syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT:
-			m.actions <- Action{Type: QUIT}
+			m.signalAction(QUIT)
 			return
 
 		case syscall.SIGHUP:
-			m.actions <- Action{Type: RELOAD}
+			m.signalAction(RELOAD)
 
 		}
 	}
 }
+
+// signalAction runs an action on behalf of a signal, with nobody waiting for
+// its outcome but the log.
+func (m *JobManager) signalAction(action string) {
+	done := make(chan bool, 1)
+	errs := make(chan *Error, 1)
+
+	m.actions <- Action{Type: action, Done: done, Err: errs}
+	if !<-done {
+		logger.Errorf("%s failed: %s", action, <-errs)
+	}
+}
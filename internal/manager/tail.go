@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/Archer-01/taskmaster/internal/utils"
+)
+
+const (
+	TAIL = "tail"
+)
+
+const (
+	STDOUT       = "stdout"
+	STDERR       = "stderr"
+	FOLLOW       = "-f"
+	LINES        = "-n"
+	TAIL_LINES   = 10
+	TAIL_MAXREAD = utils.RING_SIZE
+)
+
+type TailArgs struct {
+	Target string
+	Stream string
+	Lines  int
+	Follow bool
+}
+
+type TailSource struct {
+	LogFile string
+	Writer  *utils.DynamicWriter
+}
+
+// ParseTailArgs parses "<name> [stdout|stderr] [-n N] [-f]".
+func ParseTailArgs(args []string) (TailArgs, *Error) {
+	tail := TailArgs{Stream: STDOUT, Lines: TAIL_LINES}
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case STDOUT, STDERR:
+			tail.Stream = arg
+		case FOLLOW:
+			tail.Follow = true
+		case LINES:
+			i++
+			if i == len(args) {
+				return tail, NewError(ERR_BAD_ARGUMENTS, "%s expects a number", LINES)
+			}
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				return tail, NewError(ERR_BAD_ARGUMENTS, "%s expects a number", LINES)
+			}
+			tail.Lines = n
+		default:
+			if tail.Target != "" {
+				return tail, NewError(ERR_BAD_ARGUMENTS, "unexpected argument %s", arg)
+			}
+			tail.Target = arg
+		}
+	}
+
+	if tail.Target == "" {
+		return tail, NewError(ERR_BAD_ARGUMENTS, "tail expects a program name")
+	}
+	return tail, nil
+}
+
+func (m *JobManager) getTailSource(action Action) {
+	tail, err := ParseTailArgs(action.Args)
+	if err != nil {
+		action.Err <- err
+		action.Done <- false
+		return
+	}
+
+	j, _, err := m.findTarget(tail.Target)
+	if err != nil {
+		action.Err <- err
+		action.Done <- false
+		return
+	}
+
+	if tail.Stream == STDERR {
+		action.Tail <- TailSource{LogFile: j.StderrLogFile, Writer: j.StderrWriter}
+	} else {
+		action.Tail <- TailSource{LogFile: j.StdoutLogFile, Writer: j.StdoutWriter}
+	}
+	action.Done <- true
+}
+
+func (m *JobManager) tailSource(args []string) (TailSource, *Error) {
+	done := make(chan bool, 1)
+	defer close(done)
+
+	errs := make(chan *Error, 1)
+	defer close(errs)
+
+	source := make(chan TailSource, 1)
+	defer close(source)
+
+	m.actions <- Action{Type: TAIL, Done: done, Err: errs, Tail: source, Args: args}
+	if !<-done {
+		return TailSource{}, <-errs
+	}
+	return <-source, nil
+}
+
+func readFileTail(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := max(info.Size()-TAIL_MAXREAD, 0)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
+
+func lastLines(data []byte, n int) []byte {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if n == 0 || len(data) == 0 {
+		return nil
+	}
+
+	end := len(data)
+	for ; n > 0; n-- {
+		i := bytes.LastIndexByte(data[:end], '\n')
+		if i == -1 {
+			return data
+		}
+		end = i
+	}
+	return data[end+1:]
+}
+
+func (src TailSource) Read(lines int) (string, *Error) {
+	data := src.Writer.Recent()
+	if src.LogFile != "" {
+		content, err := readFileTail(src.LogFile)
+		if err != nil && !os.IsNotExist(err) {
+			return "", NewError(ERR_FAILED, "%s", err)
+		}
+		data = content
+	}
+	return string(lastLines(data, lines)), nil
+}
+
+func (m *JobManager) tail(args []string) *Response {
+	tail, err := ParseTailArgs(args)
+	if err != nil {
+		return BadRequest(err)
+	}
+	if tail.Follow {
+		return BadRequest(NewError(ERR_BAD_ARGUMENTS, "%s needs a streaming connection", FOLLOW))
+	}
+
+	src, err := m.tailSource(args)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	text, err := src.Read(tail.Lines)
+	if err != nil {
+		return BadRequest(err)
+	}
+	return NewResponseWithBody(text)
+}
+
+// Follow returns the current tail of a program output along with a channel
+// carrying everything it writes from now on. The returned function must be
+// called to stop following.
+func (m *JobManager) Follow(args ...string) (string, <-chan []byte, func(), *Error) {
+	tail, err := ParseTailArgs(args)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	src, err := m.tailSource(args)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	chunks, cancel := src.Writer.Follow()
+	text, err := src.Read(tail.Lines)
+	if err != nil {
+		cancel()
+		return "", nil, nil, err
+	}
+	return text, chunks, cancel, nil
+}
@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"testing"
+)
+
+func TestParseTailArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want TailArgs
+		err  bool
+	}{
+		{args: []string{"web"}, want: TailArgs{Target: "web", Stream: STDOUT, Lines: TAIL_LINES}},
+		{args: []string{"web", "stderr"}, want: TailArgs{Target: "web", Stream: STDERR, Lines: TAIL_LINES}},
+		{args: []string{"-f", "web:1", "-n", "3"}, want: TailArgs{Target: "web:1", Stream: STDOUT, Lines: 3, Follow: true}},
+		{args: []string{"web", "-n", "0"}, want: TailArgs{Target: "web", Stream: STDOUT, Lines: 0}},
+		{args: []string{}, err: true},
+		{args: []string{"stdout", "-f"}, err: true},
+		{args: []string{"web", "db"}, err: true},
+		{args: []string{"web", "-n"}, err: true},
+		{args: []string{"web", "-n", "x"}, err: true},
+		{args: []string{"web", "-n", "-1"}, err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTailArgs(tt.args)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseTailArgs(%q) = %+v, want an error", tt.args, got)
+			} else if err.Code != ERR_BAD_ARGUMENTS {
+				t.Errorf("ParseTailArgs(%q) error code = %s, want %s", tt.args, err.Code, ERR_BAD_ARGUMENTS)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTailArgs(%q) error: %s", tt.args, err.Message)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTailArgs(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	tests := []struct {
+		data string
+		n    int
+		want string
+	}{
+		{data: "", n: 10, want: ""},
+		{data: "a\nb\nc\n", n: 0, want: ""},
+		{data: "a\nb\nc\n", n: 2, want: "b\nc"},
+		{data: "a\nb\nc", n: 2, want: "b\nc"},
+		{data: "a\nb\nc\n", n: 3, want: "a\nb\nc"},
+		{data: "a\nb\nc\n", n: 10, want: "a\nb\nc"},
+		{data: "single line", n: 1, want: "single line"},
+		{data: "a\n\nb\n", n: 2, want: "\nb"},
+		{data: "\n", n: 1, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := string(lastLines([]byte(tt.data), tt.n)); got != tt.want {
+			t.Errorf("lastLines(%q, %d) = %q, want %q", tt.data, tt.n, got, tt.want)
+		}
+	}
+}
@@ -1,40 +1,190 @@
 package config
 
 import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/BurntSushi/toml"
 )
 
+type HealthCheck struct {
+	Type     string `toml:"type" validate:"default=none,enum=none|exec|tcp|http"`
+	Command  string `toml:"command"`
+	Port     int    `toml:"port" validate:"default=0,min=0,max=65535"`
+	Path     string `toml:"path" validate:"default=/"`
+	Interval int    `toml:"interval" validate:"default=10,min=1"`
+	Timeout  int    `toml:"timeout" validate:"default=5,min=1"`
+	Failures int    `toml:"failures" validate:"default=3,min=1"`
+}
+
 type Program struct {
-	Command        string   `toml:"command" validate:"required"`
-	Autostart      bool     `toml:"autostart" validate:"default=true"`
-	NumProcs       int      `toml:"numprocs" validate:"default=1,min=1"`
-	Environment    []string `toml:"environment"`
-	Directory      string   `toml:"directory"`
-	StdoutLogFile  string   `toml:"stdout_logfile"`
-	StderrLogFile  string   `toml:"stderr_logfile"`
-	Umask          string   `toml:"umask" validate:"default=0022"`
-	StartSecs      int      `toml:"startsecs" validate:"default=1,min=0"`
-	StartRetries   int      `toml:"startretries" validate:"default=3,min=0"`
-	Autorestart    string   `toml:"autorestart" validate:"default=unexpected,enum=false|unexpected|true"`
-	StopSignal     string   `toml:"stopsignal" validate:"default=TERM,enum=TERM|HUP|INT|QUIT|KILL|USR1|USR2"`
-	StopWaitSecs   int      `toml:"stopwaitsecs" validate:"default=10,min=0"`
-	ExitCodes      []int    `toml:"exitcodes"`
-	Priority       int      `toml:"priority"`
-	RedirectStderr bool     `toml:"redirect_stderr"`
-	ProcessName    string   `toml:"process_name"`
+	Command           string      `toml:"command" validate:"required"`
+	Exec              string      `toml:"exec"`
+	Autostart         bool        `toml:"autostart" validate:"default=true"`
+	NumProcs          int         `toml:"numprocs" validate:"default=1,min=1"`
+	Environment       Environment `toml:"environment"`
+	EnvFiles          []string    `toml:"env_file"`
+	CleanEnvironment  bool        `toml:"clean_environment"`
+	Directory         string      `toml:"directory"`
+	StdoutLogFile     string      `toml:"stdout_logfile"`
+	StdoutLogMaxBytes int         `toml:"stdout_logfile_maxbytes" validate:"default=0,min=0"`
+	StdoutLogBackups  int         `toml:"stdout_logfile_backups" validate:"default=10,min=0"`
+	StderrLogFile     string      `toml:"stderr_logfile"`
+	StderrLogMaxBytes int         `toml:"stderr_logfile_maxbytes" validate:"default=0,min=0"`
+	StderrLogBackups  int         `toml:"stderr_logfile_backups" validate:"default=10,min=0"`
+	User              string      `toml:"user"`
+	Group             string      `toml:"group"`
+	Umask             string      `toml:"umask" validate:"default=0022"`
+	RlimitNofile      int         `toml:"rlimit_nofile" validate:"default=-1,min=-1"`
+	RlimitAs          int         `toml:"rlimit_as" validate:"default=-1,min=-1"`
+	RlimitCore        int         `toml:"rlimit_core" validate:"default=-1,min=-1"`
+	RlimitNproc       int         `toml:"rlimit_nproc" validate:"default=-1,min=-1"`
+	RlimitCpu         int         `toml:"rlimit_cpu" validate:"default=-1,min=-1"`
+	RlimitFsize       int         `toml:"rlimit_fsize" validate:"default=-1,min=-1"`
+	RlimitData        int         `toml:"rlimit_data" validate:"default=-1,min=-1"`
+	RlimitStack       int         `toml:"rlimit_stack" validate:"default=-1,min=-1"`
+	RlimitMemlock     int         `toml:"rlimit_memlock" validate:"default=-1,min=-1"`
+	MemoryMax         string      `toml:"memory_max"`
+	CpuMax            string      `toml:"cpu_max"`
+	PidsMax           int         `toml:"pids_max" validate:"default=0,min=0"`
+	StartSecs         int         `toml:"startsecs" validate:"default=1,min=0"`
+	StartRetries      int         `toml:"startretries" validate:"default=3,min=0"`
+	BackoffInitial    int         `toml:"backoff_initial" validate:"default=1,min=1"`
+	BackoffMax        int         `toml:"backoff_max" validate:"default=60,min=1"`
+	BackoffMultiplier float64     `toml:"backoff_multiplier" validate:"default=1,min=1"`
+	BackoffJitter     float64     `toml:"backoff_jitter" validate:"default=0,min=0,max=1"`
+	Autorestart       string      `toml:"autorestart" validate:"default=unexpected,enum=false|unexpected|true"`
+	MaxRestarts       int         `toml:"max_restarts" validate:"default=0,min=0"`
+	RestartWindow     int         `toml:"restart_window" validate:"default=60,min=1"`
+	StopSignal        string      `toml:"stopsignal" validate:"default=TERM,enum=TERM|HUP|INT|QUIT|KILL|USR1|USR2"`
+	StopWaitSecs      int         `toml:"stopwaitsecs" validate:"default=10,min=0"`
+	ExitCodes         []int       `toml:"exitcodes"`
+	Priority          int         `toml:"priority"`
+	RedirectStderr    bool        `toml:"redirect_stderr"`
+	ProcessName       string      `toml:"process_name"`
+	DependsOn         []string    `toml:"depends_on"`
+	WaitDependencies  bool        `toml:"wait_for_dependencies"`
+	HealthCheck       HealthCheck `toml:"healthcheck"`
+	PreStart          string      `toml:"pre_start"`
+	PostStart         string      `toml:"post_start"`
+	PreStop           string      `toml:"pre_stop"`
+	PostStop          string      `toml:"post_stop"`
+	HookTimeout       int         `toml:"hook_timeout" validate:"default=30,min=1"`
+}
+
+// EventListener is a program fed with the events accepted by Events on its
+// standard input.
+type EventListener struct {
+	Program
+	Events     []string `toml:"events" validate:"required"`
+	BufferSize int      `toml:"buffer_size" validate:"default=10,min=1"`
 }
 
+type Group struct {
+	Programs []string `toml:"programs" validate:"required"`
+	Priority int      `toml:"priority"`
+}
+
+const (
+	EXEC_SHELL  = "shell"
+	EXEC_DIRECT = "direct"
+)
+
 type Config struct {
-	Programs map[string]*Program `toml:"program"`
-	User     string              `toml:"user"`
+	Version      int                       `toml:"version" validate:"default=1,min=1,max=2"`
+	Programs     map[string]*Program       `toml:"program"`
+	Listeners    map[string]*EventListener `toml:"eventlistener"`
+	Groups       map[string]*Group         `toml:"group"`
+	User         string                    `toml:"user"`
+	CgroupParent string                    `toml:"cgroup_parent"`
 }
 
-func ParseCommand(cmd string) []string {
-	return strings.FieldsFunc(cmd, func(r rune) bool {
-		return strings.ContainsRune(" \t\n\v\f\r", r)
-	})
+// AllPrograms returns programs and event listeners alike, keyed by name.
+func (conf *Config) AllPrograms() map[string]*Program {
+	programs := make(map[string]*Program, len(conf.Programs)+len(conf.Listeners))
+	for name, prog := range conf.Programs {
+		programs[name] = prog
+	}
+	for name, listener := range conf.Listeners {
+		programs[name] = &listener.Program
+	}
+	return programs
+}
+
+// ParseCommand splits cmd into arguments the way a POSIX shell would,
+// without any expansion: whitespace separates arguments, single quotes keep
+// everything literally, double quotes keep everything but backslash escapes
+// of $, `, ", \ and newline, and a backslash outside of quotes escapes the
+// next character.
+func ParseCommand(cmd string) ([]string, error) {
+	args := make([]string, 0)
+	var arg strings.Builder
+	inArg := false
+
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case strings.IndexByte(" \t\n\v\f\r", c) != -1:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+			continue
+
+		case c == '\\':
+			if i+1 == len(cmd) {
+				return nil, fmt.Errorf("command ends with an escape character")
+			}
+			i++
+			if cmd[i] == '\n' {
+				continue
+			}
+			arg.WriteByte(cmd[i])
+
+		case c == '\'':
+			end := strings.IndexByte(cmd[i+1:], '\'')
+			if end == -1 {
+				return nil, fmt.Errorf("command has an unterminated single quote")
+			}
+			arg.WriteString(cmd[i+1 : i+1+end])
+			i += end + 1
+
+		case c == '"':
+			closed := false
+			for i++; i < len(cmd); i++ {
+				if cmd[i] == '"' {
+					closed = true
+					break
+				}
+				if cmd[i] == '\\' && i+1 < len(cmd) && strings.IndexByte("$`\"\\\n", cmd[i+1]) != -1 {
+					i++
+					if cmd[i] == '\n' {
+						continue
+					}
+				}
+				arg.WriteByte(cmd[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("command has an unterminated double quote")
+			}
+
+		default:
+			arg.WriteByte(c)
+		}
+		inArg = true
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("command is empty")
+	}
+	return args, nil
 }
 
 func ParseConfig(file string) (Config, error) {
@@ -47,5 +197,208 @@ func ParseConfig(file string) (Config, error) {
 		return conf, err_msg
 	}
 
+	err_msg = interpolate(&conf, file)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateEnvironment(&conf, file)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateExec(&conf)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateListeners(&conf)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateGroups(&conf)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateBackoff(&conf)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateDependencies(&conf)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateHealthChecks(&conf)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateCgroups(&conf)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
+	err_msg = validateUsers(&conf)
+	if err_msg != nil {
+		return conf, err_msg
+	}
+
 	return conf, err
 }
+
+// execMode is how a program is run: as its exec key says, otherwise through
+// sh -c in version 1 configurations and directly from version 2 on.
+func (conf *Config) execMode(prog *Program) string {
+	if prog.Exec != "" {
+		return prog.Exec
+	}
+	if conf.Version >= 2 {
+		return EXEC_DIRECT
+	}
+	return EXEC_SHELL
+}
+
+func validateExec(conf *Config) error {
+	for name, prog := range conf.AllPrograms() {
+		prog.Exec = conf.execMode(prog)
+		switch prog.Exec {
+		case EXEC_SHELL, EXEC_DIRECT:
+		default:
+			return fmt.Errorf("program %s: exec must be in [%s %s]", name, EXEC_SHELL, EXEC_DIRECT)
+		}
+
+		if prog.Exec == EXEC_DIRECT {
+			if _, err := ParseCommand(prog.Command); err != nil {
+				return fmt.Errorf("program %s: %s", name, err)
+			}
+		}
+		if _, err := strconv.ParseUint(prog.Umask, 8, 32); err != nil {
+			return fmt.Errorf("program %s: umask must be an octal number", name)
+		}
+	}
+	return nil
+}
+
+func validateListeners(conf *Config) error {
+	for name := range conf.Listeners {
+		if _, found := conf.Programs[name]; found {
+			return fmt.Errorf("%s is both a program and an event listener", name)
+		}
+	}
+	return nil
+}
+
+func validateGroups(conf *Config) error {
+	programs := conf.AllPrograms()
+	owners := make(map[string]string)
+	for name, group := range conf.Groups {
+		for _, prog := range group.Programs {
+			if _, found := programs[prog]; !found {
+				return fmt.Errorf("group %s: unknown program %s", name, prog)
+			}
+			if owner, found := owners[prog]; found && owner != name {
+				return fmt.Errorf("program %s belongs to both groups %s and %s", prog, owner, name)
+			}
+			owners[prog] = name
+		}
+	}
+	return nil
+}
+
+func validateBackoff(conf *Config) error {
+	for name, prog := range conf.AllPrograms() {
+		if prog.BackoffMax < prog.BackoffInitial {
+			return fmt.Errorf("program %s: backoff_max must be at least backoff_initial", name)
+		}
+	}
+	return nil
+}
+
+func validateDependencies(conf *Config) error {
+	programs := conf.AllPrograms()
+	for name, prog := range programs {
+		for _, dep := range prog.DependsOn {
+			if _, found := programs[dep]; !found {
+				return fmt.Errorf("program %s: unknown dependency %s", name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(programs))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		}
+
+		marks[name] = visiting
+		for _, dep := range programs[name].DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		return nil
+	}
+
+	for name := range programs {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateHealthChecks(conf *Config) error {
+	for name, prog := range conf.AllPrograms() {
+		check := prog.HealthCheck
+		switch check.Type {
+		case "exec":
+			if check.Command == "" {
+				return fmt.Errorf("program %s: exec health check requires a command", name)
+			}
+		case "tcp", "http":
+			if check.Port == 0 {
+				return fmt.Errorf("program %s: %s health check requires a port", name, check.Type)
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	memoryMaxFormat = regexp.MustCompile(`^(max|[0-9]+[KMGT]?)$`)
+	cpuMaxFormat    = regexp.MustCompile(`^(max|[0-9]+)( [0-9]+)?$`)
+)
+
+func validateCgroups(conf *Config) error {
+	if conf.CgroupParent != "" && !filepath.IsAbs(conf.CgroupParent) {
+		return fmt.Errorf("cgroup_parent must be an absolute path")
+	}
+
+	for name, prog := range conf.AllPrograms() {
+		if prog.MemoryMax != "" && !memoryMaxFormat.MatchString(prog.MemoryMax) {
+			return fmt.Errorf("program %s: memory_max must be max or a size such as 512M", name)
+		}
+		if prog.CpuMax != "" && !cpuMaxFormat.MatchString(prog.CpuMax) {
+			return fmt.Errorf("program %s: cpu_max must be \"max\" or \"$QUOTA $PERIOD\" in microseconds", name)
+		}
+		if (prog.MemoryMax != "" || prog.CpuMax != "" || prog.PidsMax != 0) && conf.CgroupParent == "" {
+			return fmt.Errorf("program %s: memory_max, cpu_max and pids_max require cgroup_parent", name)
+		}
+	}
+	return nil
+}
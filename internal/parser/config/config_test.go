@@ -0,0 +1,158 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	tests := []struct {
+		name      string
+		programs  map[string][]string
+		listeners map[string][]string
+		err       string
+	}{
+		{
+			name:     "no dependencies",
+			programs: map[string][]string{"a": nil, "b": nil},
+		},
+		{
+			name:     "chain",
+			programs: map[string][]string{"a": nil, "b": {"a"}, "c": {"b", "a"}},
+		},
+		{
+			name:      "listener dependency",
+			programs:  map[string][]string{"a": {"l"}},
+			listeners: map[string][]string{"l": nil},
+		},
+		{
+			name:     "unknown dependency",
+			programs: map[string][]string{"a": {"db"}},
+			err:      "program a: unknown dependency db",
+		},
+		{
+			name:     "self dependency",
+			programs: map[string][]string{"a": {"a"}},
+			err:      "dependency cycle: a -> a",
+		},
+		{
+			name:     "cycle",
+			programs: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}, "d": nil},
+			err:      "dependency cycle",
+		},
+		{
+			name:      "cycle through a listener",
+			programs:  map[string][]string{"a": {"l"}},
+			listeners: map[string][]string{"l": {"a"}},
+			err:       "dependency cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := Config{
+				Programs:  make(map[string]*Program),
+				Listeners: make(map[string]*EventListener),
+			}
+			for name, deps := range tt.programs {
+				conf.Programs[name] = &Program{DependsOn: deps}
+			}
+			for name, deps := range tt.listeners {
+				conf.Listeners[name] = &EventListener{Program: Program{DependsOn: deps}}
+			}
+
+			err := validateDependencies(&conf)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case tt.err != "" && err == nil:
+				t.Errorf("no error, want %q", tt.err)
+			case tt.err != "" && !strings.HasPrefix(err.Error(), tt.err):
+				t.Errorf("error %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateBackoff(t *testing.T) {
+	tests := []struct {
+		initial int
+		max     int
+		err     bool
+	}{
+		{initial: 1, max: 60},
+		{initial: 5, max: 5},
+		{initial: 10, max: 5, err: true},
+	}
+
+	for _, tt := range tests {
+		conf := Config{Programs: map[string]*Program{
+			"a": {BackoffInitial: tt.initial, BackoffMax: tt.max},
+		}}
+		if err := validateBackoff(&conf); (err != nil) != tt.err {
+			t.Errorf("validateBackoff(initial=%d, max=%d) = %v, want error: %t", tt.initial, tt.max, err, tt.err)
+		}
+	}
+}
+
+func TestValidateUsers(t *testing.T) {
+	tests := []struct {
+		user string
+		prog Program
+		err  bool
+	}{
+		{prog: Program{User: "nobody", Group: "daemon"}},
+		{user: "root", prog: Program{User: "0", Group: "root"}},
+		{user: "0", prog: Program{User: "root"}},
+		{user: "root", prog: Program{User: "nobody"}, err: true},
+		{user: "root", prog: Program{Group: "daemon"}, err: true},
+		{prog: Program{User: "no-such-user-here"}, err: true},
+	}
+
+	for _, tt := range tests {
+		conf := Config{User: tt.user, Programs: map[string]*Program{"a": &tt.prog}}
+		if err := validateUsers(&conf); (err != nil) != tt.err {
+			t.Errorf("validateUsers(user=%q, program user=%q, group=%q) = %v, want error: %t", tt.user, tt.prog.User, tt.prog.Group, err, tt.err)
+		}
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want []string
+		err  string
+	}{
+		{name: "words", cmd: "  /bin/echo  a\tb\n", want: []string{"/bin/echo", "a", "b"}},
+		{name: "single quotes", cmd: `echo 'a  b' '$x "y"'`, want: []string{"echo", "a  b", `$x "y"`}},
+		{name: "double quotes", cmd: `echo "a 'b'" "c\"d" "\$e\\" "\x"`, want: []string{"echo", "a 'b'", `c"d`, `$e\`, `\x`}},
+		{name: "adjacent quotes", cmd: `echo a'b c'"d"`, want: []string{"echo", "ab cd"}},
+		{name: "empty argument", cmd: `echo '' ""`, want: []string{"echo", "", ""}},
+		{name: "escapes", cmd: `echo a\ b \'c\"`, want: []string{"echo", "a b", `'c"`}},
+		{name: "line continuation", cmd: "echo a \\\n b \"c\\\nd\"", want: []string{"echo", "a", "b", "cd"}},
+		{name: "unterminated single quote", cmd: "echo 'a", err: "command has an unterminated single quote"},
+		{name: "unterminated double quote", cmd: `echo "a\"`, err: "command has an unterminated double quote"},
+		{name: "trailing escape", cmd: `echo a\`, err: "command ends with an escape character"},
+		{name: "empty", cmd: " \t\n", err: "command is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCommand(tt.cmd)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("ParseCommand(%q) error = %v, want %q", tt.cmd, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCommand(%q): %s", tt.cmd, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
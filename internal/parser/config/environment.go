@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Environment holds KEY=VALUE entries, written either as an array of such
+// strings or as a table.
+type Environment []string
+
+func (env *Environment) UnmarshalTOML(data any) error {
+	switch data := data.(type) {
+	case []any:
+		for _, entry := range data {
+			value, ok := entry.(string)
+			if !ok {
+				return fmt.Errorf("environment entries must be strings")
+			}
+			*env = append(*env, value)
+		}
+	case map[string]any:
+		for _, key := range slices.Sorted(maps.Keys(data)) {
+			switch value := data[key].(type) {
+			case string, int64, float64, bool:
+				*env = append(*env, fmt.Sprintf("%s=%v", key, value))
+			default:
+				return fmt.Errorf("environment %s must be a string, a number or a boolean", key)
+			}
+		}
+	default:
+		return fmt.Errorf("environment must be an array or a table")
+	}
+	return nil
+}
+
+// mergeEnvironment merges lists of entries, the last value of a variable
+// winning in the place of its first one.
+func mergeEnvironment(lists ...[]string) []string {
+	merged := make([]string, 0)
+	index := make(map[string]int)
+	for _, list := range lists {
+		for _, entry := range list {
+			key, _, _ := strings.Cut(entry, "=")
+			if i, ok := index[key]; ok {
+				merged[i] = entry
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
+
+// envFileValue reads the value of a dotenv line: literal between single
+// quotes, with \n, \" and \\ escapes between double quotes, up to a
+// comment otherwise.
+func envFileValue(raw string) (string, error) {
+	if raw == "" || (raw[0] != '\'' && raw[0] != '"') {
+		if i := strings.Index(raw, " #"); i != -1 {
+			raw = raw[:i]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+
+	quote := raw[0]
+	var value strings.Builder
+	for i := 1; i < len(raw); i++ {
+		switch {
+		case raw[i] == quote:
+			if rest := strings.TrimSpace(raw[i+1:]); rest != "" && rest[0] != '#' {
+				return "", fmt.Errorf("unexpected %q after the closing quote", rest)
+			}
+			return value.String(), nil
+		case quote == '"' && raw[i] == '\\' && i+1 < len(raw):
+			i++
+			if raw[i] == 'n' {
+				value.WriteByte('\n')
+			} else {
+				value.WriteByte(raw[i])
+			}
+		default:
+			value.WriteByte(raw[i])
+		}
+	}
+	return "", fmt.Errorf("unterminated quote")
+}
+
+// ReadEnvFile reads a dotenv file: KEY=VALUE lines, optionally prefixed with
+// export, with blank lines and # comments ignored.
+func ReadEnvFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0)
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		key, raw, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || !varName.MatchString(key) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n+1)
+		}
+		value, err := envFileValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n+1, err)
+		}
+		env = append(env, key+"="+value)
+	}
+	return env, nil
+}
+
+// validateEnvironment checks the environment entries of every program and
+// merges in its env files, read relative to the configuration file, so that
+// the program entries win.
+func validateEnvironment(conf *Config, file string) error {
+	here := filepath.Dir(file)
+
+	for name, prog := range conf.AllPrograms() {
+		for _, entry := range prog.Environment {
+			if key, _, ok := strings.Cut(entry, "="); !ok || !varName.MatchString(key) {
+				return fmt.Errorf("program %s: environment entry %q is not KEY=VALUE", name, entry)
+			}
+		}
+
+		lists := make([][]string, 0, len(prog.EnvFiles)+1)
+		for _, path := range prog.EnvFiles {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(here, path)
+			}
+			env, err := ReadEnvFile(path)
+			if err != nil {
+				return fmt.Errorf("program %s: env_file: %s", name, err)
+			}
+			// values are taken as is, not interpolated per process
+			for i, entry := range env {
+				env[i] = strings.ReplaceAll(entry, "${", "$${")
+			}
+			lists = append(lists, env)
+		}
+		prog.Environment = mergeEnvironment(append(lists, prog.Environment)...)
+	}
+	return nil
+}
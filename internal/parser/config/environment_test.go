@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEnvFileValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		err  string
+	}{
+		{raw: "", want: ""},
+		{raw: "plain value", want: "plain value"},
+		{raw: "value # comment", want: "value"},
+		{raw: "a#b", want: "a#b"},
+		{raw: `'lit $HOME \n "x"'`, want: `lit $HOME \n "x"`},
+		{raw: `"a\nb \"c\" \\ \x"`, want: "a\nb \"c\" \\ x"},
+		{raw: `"value" # comment`, want: "value"},
+		{raw: `''`, want: ""},
+		{raw: `"value" trailing`, err: `unexpected "trailing" after the closing quote`},
+		{raw: `'unterminated`, err: "unterminated quote"},
+		{raw: `"escaped quote\"`, err: "unterminated quote"},
+	}
+
+	for _, tt := range tests {
+		got, err := envFileValue(tt.raw)
+		switch {
+		case tt.err != "" && (err == nil || err.Error() != tt.err):
+			t.Errorf("envFileValue(%q) error = %v, want %q", tt.raw, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("envFileValue(%q): %s", tt.raw, err)
+		case tt.err == "" && got != tt.want:
+			t.Errorf("envFileValue(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+		err  string
+	}{
+		{
+			name: "entries",
+			data: "# comment\n\nA=1\n  export B = two words \nC='x=y'\nD=\n",
+			want: []string{"A=1", "B=two words", "C=x=y", "D="},
+		},
+		{
+			name: "crlf",
+			data: "A=1\r\nB=\"2\"\r\n",
+			want: []string{"A=1", "B=2"},
+		},
+		{
+			name: "missing value",
+			data: "A=1\nB\n",
+			err:  ".env:2: expected KEY=VALUE",
+		},
+		{
+			name: "bad key",
+			data: "1A=1\n",
+			err:  ".env:1: expected KEY=VALUE",
+		},
+		{
+			name: "bad value",
+			data: "A=\"1\n",
+			err:  ".env:1: unterminated quote",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadEnvFile(path)
+			if tt.err != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.err) {
+					t.Errorf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadEnvFile(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestMergeEnvironment(t *testing.T) {
+	got := mergeEnvironment([]string{"A=1", "B=2"}, []string{"C=3", "A=4"}, []string{"B=5"})
+	want := []string{"A=4", "B=5", "C=3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("mergeEnvironment = %q, want %q", got, want)
+	}
+}
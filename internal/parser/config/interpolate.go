@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	VAR_PROGRAM_NAME = "program_name"
+	VAR_PROCESS_NUM  = "process_num"
+	VAR_HERE         = "here"
+)
+
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// expand replaces ${name} and ${name:-default} references in s with what
+// lookup finds, the default being used when the value is unset or empty.
+// $${ stands for a literal ${. Unless final, the escapes and the
+// ${process_num} references are kept for a final pass run per process.
+// With shell, the references lookup does not know and those that are not
+// names, such as ${1} or ${VAR%x}, are left as is for the shell to expand.
+func expand(s string, lookup func(string) (string, bool), final bool, shell bool) (string, error) {
+	var out strings.Builder
+
+	// escaped, a reference is turned back into itself by the final pass
+	keep := func(ref string) {
+		if !final {
+			ref = strings.ReplaceAll(ref, "${", "$${")
+		}
+		out.WriteString(ref)
+	}
+
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			if final {
+				out.WriteString("${")
+			} else {
+				out.WriteString("$${")
+			}
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			out.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end == -1 {
+			if shell {
+				keep("${")
+				i += 2
+				continue
+			}
+			return "", fmt.Errorf("unterminated ${ in %q", s)
+		}
+		ref := s[i : i+end+1]
+		name, fallback, hasDefault := strings.Cut(s[i+2:i+end], ":-")
+		i += end + 1
+
+		if !varName.MatchString(name) {
+			if shell {
+				keep(ref)
+				continue
+			}
+			return "", fmt.Errorf("bad substitution %s", ref)
+		}
+		if !final && name == VAR_PROCESS_NUM {
+			out.WriteString(ref)
+			continue
+		}
+
+		value, ok := lookup(name)
+		if !ok && shell {
+			keep(ref)
+			continue
+		}
+		if !ok || value == "" {
+			if !hasDefault && !ok {
+				return "", fmt.Errorf("%s is not set", ref)
+			}
+			if hasDefault {
+				value = fallback
+			}
+		}
+		if !final {
+			value = strings.ReplaceAll(value, "${", "$${")
+		}
+		out.WriteString(value)
+	}
+	return out.String(), nil
+}
+
+// ExpandProcess finishes the interpolation of a program setting for one of
+// its processes.
+func ExpandProcess(s string, procNum int) string {
+	value, err := expand(s, func(name string) (string, bool) {
+		if name == VAR_PROCESS_NUM {
+			return strconv.Itoa(procNum), true
+		}
+		return "", false
+	}, true, false)
+	if err != nil {
+		return s
+	}
+	return value
+}
+
+// programVariables returns the names a program sets in the environment of its
+// processes: its environment entries, those of its env files and, with a user,
+// the variables of that account.
+func programVariables(prog *Program, here string) map[string]bool {
+	names := make(map[string]bool)
+	if prog.User != "" {
+		for _, key := range []string{"HOME", "USER", "LOGNAME"} {
+			names[key] = true
+		}
+	}
+
+	entries := slices.Clone(prog.Environment)
+	for _, path := range prog.EnvFiles {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(here, path)
+		}
+		// an unreadable file is reported by validateEnvironment
+		env, _ := ReadEnvFile(path)
+		entries = append(entries, env...)
+	}
+	for _, entry := range entries {
+		key, _, _ := strings.Cut(entry, "=")
+		names[key] = true
+	}
+	return names
+}
+
+// interpolate expands the settings of every program that may refer to the
+// daemon environment and to the program itself.
+func interpolate(conf *Config, file string) error {
+	here, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	for name, prog := range conf.AllPrograms() {
+		lookup := func(key string) (string, bool) {
+			switch key {
+			case VAR_PROGRAM_NAME:
+				return name, true
+			case VAR_HERE:
+				return here, true
+			}
+			return os.LookupEnv(key)
+		}
+
+		for i, env := range prog.Environment {
+			value, err := expand(env, lookup, false, false)
+			if err != nil {
+				return fmt.Errorf("program %s: environment: %s", name, err)
+			}
+			prog.Environment[i] = value
+		}
+
+		for i, path := range prog.EnvFiles {
+			value, err := expand(path, lookup, false, false)
+			if err != nil {
+				return fmt.Errorf("program %s: env_file: %s", name, err)
+			}
+			prog.EnvFiles[i] = value
+		}
+
+		// the shell sees the program variables, not the daemon ones, so
+		// those are left to it
+		shell := conf.execMode(prog) == EXEC_SHELL
+		commandLookup := lookup
+		if shell {
+			defined := programVariables(prog, here)
+			delete(defined, VAR_PROGRAM_NAME)
+			delete(defined, VAR_HERE)
+			commandLookup = func(key string) (string, bool) {
+				if defined[key] {
+					return "", false
+				}
+				return lookup(key)
+			}
+		}
+
+		for _, field := range []struct {
+			key    string
+			value  *string
+			lookup func(string) (string, bool)
+			shell  bool
+		}{
+			{"command", &prog.Command, commandLookup, shell},
+			{"directory", &prog.Directory, lookup, false},
+			{"stdout_logfile", &prog.StdoutLogFile, lookup, false},
+			{"stderr_logfile", &prog.StderrLogFile, lookup, false},
+		} {
+			value, err := expand(*field.value, field.lookup, false, field.shell)
+			if err != nil {
+				return fmt.Errorf("program %s: %s: %s", name, field.key, err)
+			}
+			*field.value = value
+		}
+
+		// log files are shared by all the processes of a program and env
+		// files are read once for all of them
+		paths := []*string{&prog.StdoutLogFile, &prog.StderrLogFile}
+		for i := range prog.EnvFiles {
+			paths = append(paths, &prog.EnvFiles[i])
+		}
+		for _, path := range paths {
+			perProcess := false
+			value, _ := expand(*path, func(key string) (string, bool) {
+				perProcess = perProcess || key == VAR_PROCESS_NUM
+				return "", true
+			}, true, false)
+			if perProcess {
+				return fmt.Errorf("program %s: ${%s} cannot be used in log or env files", name, VAR_PROCESS_NUM)
+			}
+			*path = value
+		}
+	}
+	return nil
+}
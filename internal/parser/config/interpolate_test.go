@@ -0,0 +1,185 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"A": "a", "EMPTY": "", "NESTED": "${A}"}
+	lookup := func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		s     string
+		shell bool
+		want  string
+		err   string
+	}{
+		{s: "plain $A", want: "plain $A"},
+		{s: "${A}/${A}", want: "a/a"},
+		{s: "${A:-x}", want: "a"},
+		{s: "${EMPTY}", want: ""},
+		{s: "${EMPTY:-x}", want: "x"},
+		{s: "${UNSET:-x y}", want: "x y"},
+		{s: "${process_num}", want: "2"},
+		{s: "$${A} $${process_num}", want: "${A} ${process_num}"},
+		{s: "${NESTED}", want: "${A}"},
+		{s: "${UNSET}", err: "${UNSET} is not set"},
+		{s: "${1}", err: "bad substitution ${1}"},
+		{s: "${A%x}", err: "bad substitution ${A%x}"},
+		{s: "${A", err: "unterminated ${"},
+		{s: "${UNSET} ${1} ${A", shell: true, want: "${UNSET} ${1} ${A"},
+		{s: "${A} ${UNSET:-x}", shell: true, want: "a ${UNSET:-x}"},
+	}
+
+	for _, tt := range tests {
+		got, err := expand(tt.s, lookup, false, tt.shell)
+		if err == nil {
+			got = ExpandProcess(got, 2)
+		}
+		switch {
+		case tt.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.err)):
+			t.Errorf("expand(%q) error = %v, want %q", tt.s, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("expand(%q): %s", tt.s, err)
+		case tt.err == "" && got != tt.want:
+			t.Errorf("expand(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolateShellCommand(t *testing.T) {
+	t.Setenv("TM_QUEUE", "jobs")
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{`for i in 1 2; do echo ${i}; done`, `for i in 1 2; do echo ${i}; done`},
+		{`echo ${1} ${#} ${@} ${?}`, `echo ${1} ${#} ${@} ${?}`},
+		{`echo ${TM_VAR%x} ${TM_VAR#*/} ${#TM_VAR} ${TM_VAR:+set}`, `echo ${TM_VAR%x} ${TM_VAR#*/} ${#TM_VAR} ${TM_VAR:+set}`},
+		{`TM_X=1; echo ${TM_X} ${TM_X:-none}`, `TM_X=1; echo ${TM_X} ${TM_X:-none}`},
+		{`echo ${TM_A:-${TM_B}}`, `echo ${TM_A:-${TM_B}}`},
+		{`echo $$ $TM_QUEUE '${'`, `echo $$ $TM_QUEUE '${'`},
+		{`echo ${TM_QUEUE} ${program_name} ${process_num}`, `echo jobs web 2`},
+		{`echo $${TM_QUEUE}`, `echo ${TM_QUEUE}`},
+	}
+
+	for _, tt := range tests {
+		conf := Config{Programs: map[string]*Program{"web": {Command: tt.command}}}
+		if err := interpolate(&conf, "/etc/taskmaster/taskmaster.toml"); err != nil {
+			t.Errorf("interpolate(%q): %s", tt.command, err)
+			continue
+		}
+		if got := ExpandProcess(conf.Programs["web"].Command, 2); got != tt.want {
+			t.Errorf("interpolate(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolateShellProgramVariables(t *testing.T) {
+	t.Setenv("TM_QUEUE", "daemon")
+	t.Setenv("TM_HOST", "localhost")
+	t.Setenv("HOME", "/root")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "web.env"), []byte("TM_PORT=80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TM_PORT", "8080")
+
+	tests := []struct {
+		name string
+		prog Program
+		want string
+	}{
+		{
+			name: "environment",
+			prog: Program{Command: "echo ${TM_QUEUE} ${TM_HOST}", Environment: []string{"TM_QUEUE=program"}},
+			want: "echo ${TM_QUEUE} localhost",
+		},
+		{
+			name: "env file",
+			prog: Program{Command: "echo ${TM_PORT:-80}", EnvFiles: []string{"web.env"}},
+			want: "echo ${TM_PORT:-80}",
+		},
+		{
+			name: "user",
+			prog: Program{Command: "cd ${HOME}", User: "nobody"},
+			want: "cd ${HOME}",
+		},
+		{
+			name: "builtins",
+			prog: Program{Command: "echo ${program_name} ${process_num}", Environment: []string{"program_name=x", "process_num=x"}},
+			want: "echo web 2",
+		},
+		{
+			name: "direct",
+			prog: Program{Command: "echo ${TM_QUEUE}", Environment: []string{"TM_QUEUE=program"}, Exec: EXEC_DIRECT},
+			want: "echo daemon",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := Config{Programs: map[string]*Program{"web": &tt.prog}}
+			if err := interpolate(&conf, filepath.Join(dir, "taskmaster.toml")); err != nil {
+				t.Fatal(err)
+			}
+			if got := ExpandProcess(tt.prog.Command, 2); got != tt.want {
+				t.Errorf("command = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		prog    Program
+		err     string
+	}{
+		{
+			name:    "unset in a direct command",
+			version: 2,
+			prog:    Program{Command: "echo ${TM_UNSET}"},
+			err:     "program web: command: ${TM_UNSET} is not set",
+		},
+		{
+			name:    "shell exec in version 2",
+			version: 2,
+			prog:    Program{Command: "echo ${TM_UNSET}", Exec: EXEC_SHELL},
+		},
+		{
+			name:    "bad substitution in a directory",
+			version: 1,
+			prog:    Program{Command: "true", Directory: "/srv/${1}"},
+			err:     "program web: directory: bad substitution ${1}",
+		},
+		{
+			name:    "process number in a log file",
+			version: 1,
+			prog:    Program{Command: "true", StdoutLogFile: "/tmp/${process_num}.log"},
+			err:     "program web: ${process_num} cannot be used in log or env files",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := Config{Version: tt.version, Programs: map[string]*Program{"web": &tt.prog}}
+			err := interpolate(&conf, "/etc/taskmaster/taskmaster.toml")
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case tt.err != "" && (err == nil || err.Error() != tt.err):
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
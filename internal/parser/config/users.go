@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// LookupUser finds a user by name or, when name is a number, by uid.
+func LookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return user.LookupId(name)
+	}
+	return user.Lookup(name)
+}
+
+// LookupGroup finds a group by name or, when name is a number, by gid.
+func LookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return user.LookupGroupId(name)
+	}
+	return user.LookupGroup(name)
+}
+
+// validateUsers checks that every account exists. A daemon that drops to the
+// top-level user cannot switch accounts anymore, so its programs may only
+// name that same account.
+func validateUsers(conf *Config) error {
+	var daemon *user.User
+	if conf.User != "" {
+		u, err := LookupUser(conf.User)
+		if err != nil {
+			return fmt.Errorf("user: %s", err)
+		}
+		daemon = u
+	}
+
+	for name, prog := range conf.AllPrograms() {
+		if prog.User != "" {
+			u, err := LookupUser(prog.User)
+			if err != nil {
+				return fmt.Errorf("program %s: %s", name, err)
+			}
+			if daemon != nil && u.Uid != daemon.Uid {
+				return fmt.Errorf("program %s: user %s cannot be used when the daemon runs as %s", name, prog.User, conf.User)
+			}
+		}
+		if prog.Group != "" {
+			g, err := LookupGroup(prog.Group)
+			if err != nil {
+				return fmt.Errorf("program %s: %s", name, err)
+			}
+			if daemon != nil && g.Gid != daemon.Gid {
+				return fmt.Errorf("program %s: group %s cannot be used when the daemon runs as %s", name, prog.Group, conf.User)
+			}
+		}
+	}
+	return nil
+}
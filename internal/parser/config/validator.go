@@ -20,20 +20,27 @@ const (
 	FALSE    = "false"
 )
 
+func numeric(value reflect.Value) float64 {
+	if value.Kind() == reflect.Float64 {
+		return value.Float()
+	}
+	return float64(value.Int())
+}
+
 func handleMin(field string, tag_val string, value reflect.Value) (bool, string) {
-	num, err := strconv.Atoi(tag_val)
+	num, err := strconv.ParseFloat(tag_val, 64)
 	if err != nil {
 		return false, "Malformed schema. min must be a number"
 	}
-	return num <= value.Interface().(int), fmt.Sprintf("%s must be less than %v", field, tag_val)
+	return num <= numeric(value), fmt.Sprintf("%s must be less than %v", field, tag_val)
 }
 
 func handleMax(field string, tag_val string, value reflect.Value) (bool, string) {
-	num, err := strconv.Atoi(tag_val)
+	num, err := strconv.ParseFloat(tag_val, 64)
 	if err != nil {
 		return false, "Malformed schema. max must be a number"
 	}
-	return num >= value.Interface().(int), fmt.Sprintf("%s must be less than %v", field, tag_val)
+	return num >= numeric(value), fmt.Sprintf("%s must be less than %v", field, tag_val)
 }
 
 func handleEnum(field string, tag_val string, value reflect.Value) (bool, string) {
@@ -78,6 +85,13 @@ func validate_struct(v reflect.Value, path []string, md toml.MetaData) error {
 	for i := 0; i < v.NumField(); i++ {
 		field_name := v.Type().Field(i).Name
 		field_value := v.Field(i)
+		if v.Type().Field(i).Anonymous {
+			// embedded structs are decoded from the same table
+			if err := validate_struct(field_value, path, md); err != nil {
+				return err
+			}
+			continue
+		}
 		tag := v.Type().Field(i).Tag.Get("validate")
 		toml_tag := v.Type().Field(i).Tag.Get("toml")
 		if toml_tag == "" {
@@ -116,6 +130,12 @@ func validate_struct(v reflect.Value, path []string, md toml.MetaData) error {
 							return fmt.Errorf("Malformed schema. Default doesnt follow the field type: boolean\n")
 						}
 						field_value.Set(reflect.ValueOf(num))
+					case reflect.Float64:
+						num, err := strconv.ParseFloat(rule_value, 64)
+						if err != nil {
+							return fmt.Errorf("Malformed schema. Default doesnt follow the field type: float\n")
+						}
+						field_value.Set(reflect.ValueOf(num))
 					case reflect.String:
 						field_value.Set(reflect.ValueOf(rule_value))
 					default:
@@ -6,13 +6,16 @@ import (
 )
 
 const (
-	RELOAD  = "reload"
-	RESTART = "restart"
-	START   = "start"
-	STATUS  = "status"
-	STOP    = "stop"
-	QUIT    = "quit"
-	EXIT    = "exit"
+	RELOAD    = "reload"
+	RESTART   = "restart"
+	START     = "start"
+	STATUS    = "status"
+	STOP      = "stop"
+	TAIL      = "tail"
+	CLEAR     = "clear"
+	SUBSCRIBE = "subscribe"
+	QUIT      = "quit"
+	EXIT      = "exit"
 )
 
 func Parse(line string) ([]string, error) {
@@ -23,7 +26,7 @@ func Parse(line string) ([]string, error) {
 	}
 
 	switch args[0] {
-	case RESTART, START, STATUS, STOP:
+	case RESTART, START, STATUS, STOP, TAIL, CLEAR, SUBSCRIBE:
 		return args, nil
 
 	case RELOAD, QUIT, EXIT:
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Archer-01/taskmaster/internal/logger"
+	"github.com/Archer-01/taskmaster/internal/manager"
+)
+
+const (
+	UNIX_PREFIX = "unix:"
+)
+
+type HttpServer struct {
+	j      *manager.JobManager
+	addr   string
+	sock   net.Listener
+	server *http.Server
+}
+
+func NewHttpServer(addr string, m *manager.JobManager) *HttpServer {
+	var s HttpServer
+
+	s.addr = addr
+	s.j = m
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /programs", s.handleStatus)
+	mux.HandleFunc("GET /programs/{name}", s.handleStatus)
+	mux.HandleFunc("POST /programs/{name}/{action}", s.handleAction)
+	mux.HandleFunc("POST /reload", s.handleDaemon(manager.RELOAD))
+	mux.HandleFunc("POST /quit", s.handleDaemon(manager.QUIT))
+	s.server = &http.Server{Handler: mux}
+
+	return &s
+}
+
+// Listen opens addr, which is either "unix:/path/to/socket" or a
+// host:port pair bound to a loopback address.
+func Listen(addr string) (net.Listener, error) {
+	if path, found := strings.CutPrefix(addr, UNIX_PREFIX); found {
+		return net.Listen("unix", path)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("%s: only unix sockets and loopback addresses are allowed", addr)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
+
+func (s *HttpServer) Init() error {
+	sock, err := Listen(s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.sock = sock
+	return nil
+}
+
+func (s *HttpServer) Start(wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+	logger.Infof("Starting http server on %s...", s.addr)
+
+	err := s.server.Serve(s.sock)
+	if err != nil && err != http.ErrServerClosed {
+		logger.Error(err)
+	}
+}
+
+func (s *HttpServer) Stop() {
+	logger.Info("Closing http server...")
+	s.server.Close()
+	if path, found := strings.CutPrefix(s.addr, UNIX_PREFIX); found {
+		os.Remove(path)
+	}
+}
+
+func httpStatus(reply *Reply) int {
+	if reply.Error == nil {
+		return http.StatusOK
+	}
+	switch reply.Error.Code {
+	case manager.ERR_NOT_FOUND:
+		return http.StatusNotFound
+	case manager.ERR_BAD_ARGUMENTS, manager.ERR_UNKNOWN_COMMAND:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeReply(w http.ResponseWriter, reply *Reply) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(reply))
+	json.NewEncoder(w).Encode(reply)
+}
+
+func (s *HttpServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name == "" {
+		name = manager.ALL
+	}
+	writeReply(w, NewReply(nil, s.j.Execute(manager.STATUS, name)))
+}
+
+func (s *HttpServer) handleAction(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	switch action {
+	case manager.START, manager.STOP, manager.RESTART, manager.CLEAR:
+		writeReply(w, NewReply(nil, s.j.Execute(action, r.PathValue("name"))))
+	default:
+		writeReply(w, errorReply(nil, manager.ERR_UNKNOWN_COMMAND, fmt.Sprintf("%s Unknown command", action)))
+	}
+}
+
+func (s *HttpServer) handleDaemon(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReply(w, NewReply(nil, s.j.Execute(action)))
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Archer-01/taskmaster/internal/job"
+	"github.com/Archer-01/taskmaster/internal/manager"
+)
+
+const (
+	METRICS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// NewMetricsServer serves GET /metrics in the Prometheus text format.
+func NewMetricsServer(addr string, m *manager.JobManager, control *Server) *HttpServer {
+	var s HttpServer
+
+	s.addr = addr
+	s.j = m
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", METRICS_CONTENT_TYPE)
+		writeMetrics(w, m.Metrics(), control)
+	})
+	s.server = &http.Server{Handler: mux}
+
+	return &s
+}
+
+type metricWriter struct {
+	w io.Writer
+}
+
+func (mw metricWriter) header(name string, kind string, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one value; labels go by name and value pairs.
+func (mw metricWriter) sample(name string, value any, labels ...string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1])))
+	}
+	if len(pairs) == 0 {
+		fmt.Fprintf(mw.w, "%s %v\n", name, value)
+	} else {
+		fmt.Fprintf(mw.w, "%s{%s} %v\n", name, strings.Join(pairs, ","), value)
+	}
+}
+
+func processLabels(m job.Metrics, labels ...string) []string {
+	return append([]string{"program", m.Program, "process", m.Name}, labels...)
+}
+
+func writeMetrics(w io.Writer, metrics []job.Metrics, control *Server) {
+	mw := metricWriter{w}
+
+	mw.header("taskmaster_process_state", "gauge", "Current state of each process, 1 for the state it is in.")
+	for _, m := range metrics {
+		for _, state := range job.States {
+			value := 0
+			if m.State == state {
+				value = 1
+			}
+			mw.sample("taskmaster_process_state", value, processLabels(m, "state", state)...)
+		}
+	}
+
+	mw.header("taskmaster_process_uptime_seconds", "gauge", "Seconds since the process was started, 0 when it is not running.")
+	for _, m := range metrics {
+		mw.sample("taskmaster_process_uptime_seconds", m.Uptime, processLabels(m)...)
+	}
+
+	mw.header("taskmaster_process_pid", "gauge", "Pid of the process, 0 when it is not running.")
+	for _, m := range metrics {
+		mw.sample("taskmaster_process_pid", m.Pid, processLabels(m)...)
+	}
+
+	mw.header("taskmaster_process_starts_total", "counter", "Times the process was successfully spawned.")
+	for _, m := range metrics {
+		mw.sample("taskmaster_process_starts_total", m.Starts, processLabels(m)...)
+	}
+
+	mw.header("taskmaster_process_exits_total", "counter", "Process exits by exit code, or by signal name when it was killed.")
+	for _, m := range metrics {
+		codes := make([]string, 0, len(m.Exits))
+		for code := range m.Exits {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		for _, code := range codes {
+			mw.sample("taskmaster_process_exits_total", m.Exits[code], processLabels(m, "code", code)...)
+		}
+	}
+
+	mw.header("taskmaster_process_fatal_total", "counter", "Times the process went FATAL.")
+	for _, m := range metrics {
+		mw.sample("taskmaster_process_fatal_total", m.Fatals, processLabels(m)...)
+	}
+
+	programs := make(map[string]bool)
+	for _, m := range metrics {
+		programs[m.Program] = true
+	}
+	mw.header("taskmaster_jobs", "gauge", "Programs managed by the daemon.")
+	mw.sample("taskmaster_jobs", len(programs))
+	mw.header("taskmaster_processes", "gauge", "Processes managed by the daemon.")
+	mw.sample("taskmaster_processes", len(metrics))
+
+	open, total := control.Connections()
+	mw.header("taskmaster_control_connections", "gauge", "Open control socket connections.")
+	mw.sample("taskmaster_control_connections", open)
+	mw.header("taskmaster_control_connections_total", "counter", "Control socket connections accepted.")
+	mw.sample("taskmaster_control_connections_total", total)
+}
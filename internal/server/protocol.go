@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/Archer-01/taskmaster/internal/events"
+	"github.com/Archer-01/taskmaster/internal/job"
+	"github.com/Archer-01/taskmaster/internal/manager"
+)
+
+const (
+	PROTOCOL_VERSION = 1
+	JSON_DEL         = '\n'
+)
+
+const (
+	ERR_BAD_REQUEST         = "BAD_REQUEST"
+	ERR_UNSUPPORTED_VERSION = "UNSUPPORTED_VERSION"
+)
+
+// Request is a single newline-delimited JSON command sent on the control
+// socket. Connections whose first byte is '{' speak this protocol instead
+// of the DEL-delimited text one.
+type Request struct {
+	Version int             `json:"version"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Command string          `json:"command"`
+	Args    []string        `json:"args,omitempty"`
+}
+
+type ReplyError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Reply struct {
+	Version int             `json:"version"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Ok      bool            `json:"ok"`
+	Data    string          `json:"data,omitempty"`
+	Status  []job.Status    `json:"status,omitempty"`
+	Event   *events.Event   `json:"event,omitempty"`
+	Error   *ReplyError     `json:"error,omitempty"`
+}
+
+func NewReply(id json.RawMessage, res *manager.Response) *Reply {
+	reply := &Reply{
+		Version: PROTOCOL_VERSION,
+		Id:      id,
+		Ok:      res.Err == nil,
+	}
+
+	if res.Err != nil {
+		var err *manager.Error
+		if errors.As(res.Err, &err) {
+			reply.Error = &ReplyError{Code: err.Code, Message: err.Message}
+		} else {
+			reply.Error = &ReplyError{Code: manager.ERR_FAILED, Message: res.Err.Error()}
+		}
+		return reply
+	}
+
+	if res.Status != nil {
+		reply.Status = res.Status
+	} else {
+		reply.Data = res.Data
+	}
+	return reply
+}
+
+func (r *Reply) Response() *manager.Response {
+	if r.Error != nil {
+		return manager.BadRequest(manager.NewError(r.Error.Code, "%s", r.Error.Message))
+	}
+	if r.Status != nil {
+		return manager.NewStatusResponse(r.Status)
+	}
+	if r.Event != nil {
+		return manager.NewResponseWithBody(events.Format(*r.Event) + "\n")
+	}
+	return manager.NewResponseWithBody(r.Data)
+}
+
+func errorReply(id json.RawMessage, code string, message string) *Reply {
+	return &Reply{
+		Version: PROTOCOL_VERSION,
+		Id:      id,
+		Ok:      false,
+		Error:   &ReplyError{Code: code, Message: message},
+	}
+}
+
+func encodeReply(reply *Reply) []byte {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		data, _ = json.Marshal(errorReply(reply.Id, manager.ERR_FAILED, err.Error()))
+	}
+	return append(data, JSON_DEL)
+}
+
+func decodeRequest(line string) (*Request, *Reply) {
+	var req Request
+
+	if err := json.Unmarshal([]byte(line), &req); err != nil {
+		return nil, errorReply(nil, ERR_BAD_REQUEST, err.Error())
+	} else if req.Version != PROTOCOL_VERSION {
+		return nil, errorReply(req.Id, ERR_UNSUPPORTED_VERSION, "unsupported protocol version")
+	} else if req.Command == "" {
+		return nil, errorReply(req.Id, ERR_BAD_REQUEST, "missing command")
+	}
+	return &req, nil
+}
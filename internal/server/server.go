@@ -4,6 +4,7 @@ import (
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Archer-01/taskmaster/internal/logger"
@@ -26,6 +27,8 @@ type Server struct {
 	sock    net.Listener
 	done    chan bool
 	sockets chan SocketAction
+	open    atomic.Int64
+	total   atomic.Uint64
 }
 
 func NewServer(addr string, m *manager.JobManager) *Server {
@@ -74,9 +77,25 @@ func (s *Server) HandleSocketsList(wg *sync.WaitGroup) {
 		switch val.add {
 		case true:
 			s.conns[val.socket] = false
+			s.total.Add(1)
 		case false:
 			delete(s.conns, val.socket)
 		}
+		s.open.Store(int64(len(s.conns)))
+	}
+}
+
+// Connections returns how many control connections are open, and how many
+// were accepted since the daemon started.
+func (s *Server) Connections() (int64, uint64) {
+	return s.open.Load(), s.total.Load()
+}
+
+func (s *Server) forget(socket *Socket) {
+	select {
+	case <-s.done:
+	default:
+		s.sockets <- SocketAction{socket, false}
 	}
 }
 
@@ -12,9 +12,10 @@ import (
 )
 
 type Socket struct {
-	Con net.Conn
-	Buf string
-	Rd  *bufio.Reader
+	Con  net.Conn
+	Buf  string
+	Rd   *bufio.Reader
+	JSON bool
 }
 
 func NewSocket(conn net.Conn) *Socket {
@@ -28,6 +29,15 @@ func (s *Socket) Close() {
 	s.Con.Close()
 }
 
+func (s *Socket) detectProtocol() error {
+	first, err := s.Rd.Peek(1)
+	if err != nil {
+		return err
+	}
+	s.JSON = first[0] == '{'
+	return nil
+}
+
 func parse(text string) (string, []string, error) {
 	args := strings.FieldsFunc(text, func(r rune) bool {
 		return strings.ContainsRune(" \t\n\v\f\r", r)
@@ -43,7 +53,10 @@ func (_sv *Server) handleConnection(del byte, s *Socket, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer s.Close()
 
-	var er error = nil
+	var er error = s.detectProtocol()
+	if s.JSON {
+		del = JSON_DEL
+	}
 
 	for {
 		select {
@@ -78,11 +91,29 @@ func (_sv *Server) handleConnection(del byte, s *Socket, wg *sync.WaitGroup) {
 			line = s.Buf + line[:size-1]
 			s.Buf = ""
 
+			if s.JSON {
+				req, reply := decodeRequest(line)
+				if reply != nil {
+					s.Con.Write(encodeReply(reply))
+				} else if isStream(req.Command, req.Args) {
+					_sv.stream(s, del, req.Id, req.Command, req.Args)
+					return
+				} else {
+					s.Con.Write(encodeReply(NewReply(req.Id, _sv.j.Execute(req.Command, req.Args...))))
+				}
+				continue
+			}
+
 			cmd, args, err := parse(line)
 			if err != nil {
 				s.Con.Write([]byte(err.Error()))
 			}
 
+			if isStream(cmd, args) {
+				_sv.stream(s, del, nil, cmd, args)
+				return
+			}
+
 			res := _sv.j.Execute(cmd, args...)
 			if res.Err != nil {
 				s.Con.Write([]byte(res.Err.Error() + string(del)))
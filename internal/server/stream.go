@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+
+	"github.com/Archer-01/taskmaster/internal/events"
+	"github.com/Archer-01/taskmaster/internal/manager"
+)
+
+func isStream(cmd string, args []string) bool {
+	switch cmd {
+	case manager.TAIL:
+		return slices.Contains(args, manager.FOLLOW)
+	case manager.SUBSCRIBE:
+		return true
+	}
+	return false
+}
+
+func (_sv *Server) stream(s *Socket, del byte, id json.RawMessage, cmd string, args []string) {
+	switch cmd {
+	case manager.TAIL:
+		_sv.follow(s, del, id, args)
+	case manager.SUBSCRIBE:
+		_sv.subscribe(s, id, args)
+	}
+	_sv.forget(s)
+}
+
+func (s *Socket) writeChunk(id json.RawMessage, data string) error {
+	var err error
+	if s.JSON {
+		_, err = s.Con.Write(encodeReply(&Reply{Version: PROTOCOL_VERSION, Id: id, Ok: true, Data: data}))
+	} else {
+		_, err = s.Con.Write([]byte(data))
+	}
+	return err
+}
+
+// streamTo hands everything received on ch to write until the client hangs
+// up, the server stops, ch is closed or write fails.
+func streamTo[T any](_sv *Server, s *Socket, ch <-chan T, write func(T) error) {
+	hangup := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, s.Rd)
+		close(hangup)
+	}()
+
+	for {
+		select {
+		case <-_sv.done:
+			return
+		case <-hangup:
+			return
+		case v, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := write(v); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// follow streams a program output on s. The initial tail is sent as a
+// regular reply, followed by raw chunks in text mode or one reply per chunk
+// in JSON mode.
+func (_sv *Server) follow(s *Socket, del byte, id json.RawMessage, args []string) {
+	text, chunks, cancel, err := _sv.j.Follow(args...)
+	if err != nil {
+		if s.JSON {
+			s.Con.Write(encodeReply(NewReply(id, manager.BadRequest(err))))
+		} else {
+			s.Con.Write([]byte(err.Error() + string(del)))
+		}
+		return
+	}
+	defer cancel()
+
+	if text != "" {
+		text += "\n"
+	}
+	if s.JSON {
+		s.Con.Write(encodeReply(&Reply{Version: PROTOCOL_VERSION, Id: id, Ok: true, Data: text}))
+	} else {
+		s.Con.Write([]byte(text + string(del)))
+	}
+
+	streamTo(_sv, s, chunks, func(chunk []byte) error {
+		return s.writeChunk(id, string(chunk))
+	})
+}
+
+// subscribe pushes one line per event in text mode, or one reply carrying
+// the event in JSON mode, after acknowledging the subscription.
+func (_sv *Server) subscribe(s *Socket, id json.RawMessage, args []string) {
+	sub := events.Subscribe(events.ParseFilter(args))
+	defer events.Unsubscribe(sub)
+
+	if s.JSON {
+		s.Con.Write(encodeReply(&Reply{Version: PROTOCOL_VERSION, Id: id, Ok: true}))
+	}
+
+	streamTo(_sv, s, sub.C, func(e events.Event) error {
+		var err error
+		if s.JSON {
+			_, err = s.Con.Write(encodeReply(&Reply{Version: PROTOCOL_VERSION, Id: id, Ok: true, Event: &e}))
+		} else {
+			_, err = s.Con.Write([]byte(events.Format(e) + "\n"))
+		}
+		return err
+	})
+}
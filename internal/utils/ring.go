@@ -0,0 +1,46 @@
+package utils
+
+const (
+	RING_SIZE = 64 * 1024
+)
+
+// RingBuffer keeps the last RING_SIZE bytes written to it.
+type RingBuffer struct {
+	buf  []byte
+	pos  int
+	full bool
+}
+
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	if r.buf == nil {
+		r.buf = make([]byte, RING_SIZE)
+	}
+
+	n := len(p)
+	if n >= len(r.buf) {
+		copy(r.buf, p[n-len(r.buf):])
+		r.pos = 0
+		r.full = true
+		return n, nil
+	}
+
+	written := copy(r.buf[r.pos:], p)
+	if written < n {
+		copy(r.buf, p[written:])
+		r.full = true
+	}
+	if r.pos+n >= len(r.buf) {
+		r.full = true
+	}
+	r.pos = (r.pos + n) % len(r.buf)
+	return n, nil
+}
+
+func (r *RingBuffer) Bytes() []byte {
+	if !r.full {
+		return append([]byte(nil), r.buf[:r.pos]...)
+	}
+	out := make([]byte, 0, len(r.buf))
+	out = append(out, r.buf[r.pos:]...)
+	return append(out, r.buf[:r.pos]...)
+}
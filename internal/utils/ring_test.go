@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRingBuffer(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789abcdef"), RING_SIZE/16)
+
+	tests := []struct {
+		name   string
+		writes [][]byte
+		want   []byte
+	}{
+		{
+			name: "empty",
+			want: []byte{},
+		},
+		{
+			name:   "partial",
+			writes: [][]byte{[]byte("hello "), []byte("world")},
+			want:   []byte("hello world"),
+		},
+		{
+			name:   "exactly full",
+			writes: [][]byte{big},
+			want:   big,
+		},
+		{
+			name:   "single write larger than the ring",
+			writes: [][]byte{append([]byte("dropped"), big...)},
+			want:   big,
+		},
+		{
+			name:   "wraps around",
+			writes: [][]byte{[]byte("dropped"), big[:RING_SIZE-3], []byte("tail!")},
+			want:   append(append([]byte{}, big[2:RING_SIZE-3]...), "tail!"...),
+		},
+		{
+			name:   "fills up to the end",
+			writes: [][]byte{[]byte("abc"), big[:RING_SIZE-3], []byte("xyz")},
+			want:   append(append([]byte{}, big[:RING_SIZE-3]...), "xyz"...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r RingBuffer
+			for _, w := range tt.writes {
+				if n, err := r.Write(w); err != nil || n != len(w) {
+					t.Fatalf("Write = %d, %v", n, err)
+				}
+			}
+			got := r.Bytes()
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Bytes = %d bytes ending with %q, want %d bytes ending with %q",
+					len(got), got[max(len(got)-8, 0):], len(tt.want), tt.want[max(len(tt.want)-8, 0):])
+			}
+		})
+	}
+}
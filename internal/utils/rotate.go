@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a log file that rolls over to file.1, file.2, ... once it
+// grows past maxBytes. A maxBytes of 0 disables rotation, and 0 backups
+// truncates the file in place instead of keeping older copies.
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	key      string
+	maxBytes int64
+	backups  int
+	file     *os.File
+	size     int64
+	refs     int
+}
+
+// files opened more than once, by the stdout and stderr of a program or by
+// several programs, share a single RotatingFile so that its size is right
+var (
+	muopened sync.Mutex
+	opened   = make(map[string]*RotatingFile)
+)
+
+// OpenRotatingFile opens path, or shares it when it is already open, the
+// latest maxBytes and backups applying. Each open needs its own Close.
+func OpenRotatingFile(path string, maxBytes int64, backups int) (*RotatingFile, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	muopened.Lock()
+	defer muopened.Unlock()
+
+	if r, ok := opened[key]; ok {
+		r.mu.Lock()
+		r.maxBytes = maxBytes
+		r.backups = backups
+		r.refs++
+		r.mu.Unlock()
+		return r, nil
+	}
+
+	r := &RotatingFile{
+		path:     path,
+		key:      key,
+		maxBytes: maxBytes,
+		backups:  backups,
+		refs:     1,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	opened[key] = r
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, err := OpenLogFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) backupName(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	if r.backups == 0 {
+		if err := os.Truncate(r.path, 0); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+
+	for n := r.backups - 1; n > 0; n-- {
+		err := os.Rename(r.backupName(n), r.backupName(n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	err := os.Rename(r.path, r.backupName(1))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return r.open()
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) Close() error {
+	muopened.Lock()
+	defer muopened.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refs == 0 {
+		return nil
+	}
+	r.refs--
+	if r.refs != 0 {
+		return nil
+	}
+	delete(opened, r.key)
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
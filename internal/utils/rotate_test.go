@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int64
+		backups  int
+		writes   []string
+		want     map[string]string
+	}{
+		{
+			name:     "no rotation",
+			maxBytes: 0,
+			backups:  2,
+			writes:   []string{"aaaa\n", "bbbb\n", "cccc\n"},
+			want:     map[string]string{"out.log": "aaaa\nbbbb\ncccc\n"},
+		},
+		{
+			name:     "rollover",
+			maxBytes: 10,
+			backups:  2,
+			writes:   []string{"aaaa\n", "bbbb\n", "cccc\n"},
+			want:     map[string]string{"out.log": "cccc\n", "out.log.1": "aaaa\nbbbb\n"},
+		},
+		{
+			name:     "oldest backup dropped",
+			maxBytes: 5,
+			backups:  2,
+			writes:   []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"},
+			want: map[string]string{
+				"out.log":   "dddd\n",
+				"out.log.1": "cccc\n",
+				"out.log.2": "bbbb\n",
+				"out.log.3": "",
+			},
+		},
+		{
+			name:     "truncate without backups",
+			maxBytes: 5,
+			backups:  0,
+			writes:   []string{"aaaa\n", "bbbb\n"},
+			want:     map[string]string{"out.log": "bbbb\n", "out.log.1": ""},
+		},
+		{
+			name:     "write larger than maxBytes",
+			maxBytes: 4,
+			backups:  1,
+			writes:   []string{"aaaaaaaa\n", "b\n"},
+			want:     map[string]string{"out.log": "b\n", "out.log.1": "aaaaaaaa\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r, err := OpenRotatingFile(filepath.Join(dir, "out.log"), tt.maxBytes, tt.backups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.writes {
+				if _, err := r.Write([]byte(w)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r.Close()
+
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if want == "" {
+					if !os.IsNotExist(err) {
+						t.Errorf("%s exists with %q", name, data)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != want {
+					t.Errorf("%s = %q, want %q", name, data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingFileShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+
+	stdout, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != stderr {
+		t.Fatal("the same path was opened twice")
+	}
+
+	stdout.Write([]byte("out1\n"))
+	stderr.Write([]byte("err1\n"))
+	stdout.Write([]byte("out2\n"))
+
+	stdout.Close()
+	if _, err := stderr.Write([]byte("err2\n")); err != nil {
+		t.Fatalf("write after the other output closed: %s", err)
+	}
+	stderr.Close()
+	if _, err := stderr.Write([]byte("err3\n")); err != os.ErrClosed {
+		t.Errorf("write after the last close: %v, want %v", err, os.ErrClosed)
+	}
+
+	for name, want := range map[string]string{path: "out2\nerr2\n", path + ".1": "out1\nerr1\n"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+}
@@ -1,28 +1,95 @@
 package utils
 
 import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
-	"os/user"
 
 	"github.com/Archer-01/taskmaster/internal/parser/config"
 	"github.com/BurntSushi/toml"
 )
 
 type Setup struct {
-	Prompt string `toml:"prompt"`
-	Socket string `toml:"socket" validate:"default=/tmp/taskmaster.sock"`
-	Config string `toml:"config" validate:"default=taskmaster.toml"`
+	Prompt  string `toml:"prompt"`
+	Socket  string `toml:"socket" validate:"default=/tmp/taskmaster.sock"`
+	Config  string `toml:"config" validate:"default=taskmaster.toml"`
+	Http    string `toml:"http"`
+	Metrics string `toml:"metrics"`
 }
 
 const (
-	CONF = "setup.toml"
+	CONF      = "setup.toml"
+	SETUP_ENV = "TASKMASTER_SETUP"
 )
 
-func ParseSetupFile() (Setup, error) {
+func setupDirs() []string {
+	dirs := []string{"."}
+
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			xdg = filepath.Join(home, ".config")
+		}
+	}
+	if xdg != "" {
+		dirs = append(dirs, filepath.Join(xdg, "taskmaster"))
+	}
+
+	return append(dirs, "/etc/taskmaster")
+}
+
+// FindSetupFile returns path when set, then $TASKMASTER_SETUP, then the
+// first setup.toml found in the current directory,
+// $XDG_CONFIG_HOME/taskmaster and /etc/taskmaster.
+func FindSetupFile(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
+	if env := os.Getenv(SETUP_ENV); env != "" {
+		return env, nil
+	}
+
+	dirs := setupDirs()
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, CONF)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s not found in %s", CONF, strings.Join(dirs, ", "))
+}
+
+func resolvePath(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// resolveAddr resolves the path of "unix:" addresses, leaving host:port
+// pairs alone.
+func resolveAddr(dir string, addr string) string {
+	if sock, found := strings.CutPrefix(addr, "unix:"); found {
+		return "unix:" + resolvePath(dir, sock)
+	}
+	return addr
+}
+
+func ParseSetupFile(path string) (Setup, error) {
 	var setup Setup
 
-	mdata, err := toml.DecodeFile(CONF, &setup)
+	path, err := FindSetupFile(path)
+	if err != nil {
+		return setup, err
+	}
+
+	mdata, err := toml.DecodeFile(path, &setup)
 	if err != nil {
 		return setup, err
 	}
@@ -32,25 +99,80 @@ func ParseSetupFile() (Setup, error) {
 		return setup, err
 	}
 
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return setup, err
+	}
+
+	dir := filepath.Dir(path)
+	setup.Config = resolvePath(dir, setup.Config)
+	setup.Socket = resolvePath(dir, setup.Socket)
+	setup.Http = resolveAddr(dir, setup.Http)
+	setup.Metrics = resolveAddr(dir, setup.Metrics)
+
 	return setup, nil
 }
 
-func DeEscalatePrivilege(username string) error {
-	u, err := user.Lookup(username)
+// AccountEnv returns the HOME, USER and LOGNAME variables of an account.
+func AccountEnv(u *user.User) []string {
+	return []string{"HOME=" + u.HomeDir, "USER=" + u.Username, "LOGNAME=" + u.Username}
+}
 
+// DeEscalatePrivilege switches the daemon to username for good: supplementary
+// groups, then group, then user, so that root can never be regained. Being
+// already that user, as on a reload, is not an error.
+func DeEscalatePrivilege(username string) error {
+	u, err := config.LookupUser(username)
 	if err != nil {
 		return err
 	}
 
-	newUid, err := strconv.Atoi(u.Uid)
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return err
+	}
 
+	gid, err := strconv.Atoi(u.Gid)
 	if err != nil {
 		return err
 	}
 
-	if err := syscall.Setuid(newUid); err != nil {
+	if os.Getuid() == uid && os.Geteuid() == uid && os.Getgid() == gid && os.Getegid() == gid {
+		return nil
+	}
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("cannot switch to user %s without root privileges", u.Username)
+	}
+
+	groupIds, err := u.GroupIds()
+	if err != nil {
 		return err
 	}
+	groups := make([]int, 0, len(groupIds))
+	for _, id := range groupIds {
+		group, err := strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, group)
+	}
 
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("setgroups: %s", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %s", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %s", err)
+	}
+	if uid != 0 && syscall.Setuid(0) == nil {
+		return fmt.Errorf("root privileges could be regained after switching to user %s", u.Username)
+	}
+
+	for _, env := range AccountEnv(u) {
+		name, value, _ := strings.Cut(env, "=")
+		os.Setenv(name, value)
+	}
 	return nil
 }
@@ -8,9 +8,15 @@ import (
 	"syscall"
 )
 
+const (
+	FOLLOW_BUFFER = 64
+)
+
 type DynamicWriter struct {
-	mu     sync.RWMutex
-	writer io.Writer
+	mu        sync.RWMutex
+	writer    io.Writer
+	ring      RingBuffer
+	followers map[chan []byte]struct{}
 }
 
 func Hello(name string) string {
@@ -58,10 +64,31 @@ func ParseSignal(str string) syscall.Signal {
 	}
 }
 
+func SignalName(sig syscall.Signal) string {
+	for _, name := range []string{"TERM", "HUP", "INT", "QUIT", "KILL", "USR1", "USR2"} {
+		if ParseSignal(name) == sig {
+			return name
+		}
+	}
+	return sig.String()
+}
+
 func (d *DynamicWriter) Write(p []byte) (int, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	d.ring.Write(p)
+	if len(d.followers) != 0 {
+		chunk := append([]byte(nil), p...)
+		for ch := range d.followers {
+			// slow followers lose output rather than blocking the program
+			select {
+			case ch <- chunk:
+			default:
+			}
+		}
+	}
+
 	if d.writer == nil {
 		return 0, io.ErrClosedPipe
 	}
@@ -69,8 +96,37 @@ func (d *DynamicWriter) Write(p []byte) (int, error) {
 	return d.writer.Write(p)
 }
 
-func (d *DynamicWriter) SetWriter(w io.Writer) {
+func (d *DynamicWriter) SetWriter(w io.Writer) io.Writer {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	old := d.writer
 	d.writer = w
+	return old
+}
+
+func (d *DynamicWriter) Recent() []byte {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.ring.Bytes()
+}
+
+func (d *DynamicWriter) Follow() (<-chan []byte, func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.followers == nil {
+		d.followers = make(map[chan []byte]struct{})
+	}
+	ch := make(chan []byte, FOLLOW_BUFFER)
+	d.followers[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			delete(d.followers, ch)
+			close(ch)
+		})
+	}
 }